@@ -1,9 +1,13 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
+	"strings"
 
 	"github.com/chzyer/readline"
 	"github.com/fatih/color"
@@ -11,10 +15,14 @@ import (
 	"github.com/ivf8/simp-shell/pkg/interpreter"
 	"github.com/ivf8/simp-shell/pkg/parser"
 	"github.com/ivf8/simp-shell/pkg/scanner"
+	"github.com/ivf8/simp-shell/pkg/token"
 )
 
+// Prompt shown while reading the rest of an incomplete command
+const PS2 = "> "
+
 // Reads a continued command
-func reader(prompt string) (string, error) {
+func readContinuation(prompt string) (string, error) {
 	reader, err := readline.New(prompt)
 	if err != nil {
 		color.Red(err.Error())
@@ -40,29 +48,122 @@ func reader(prompt string) (string, error) {
 	}
 }
 
-// Runs a single line
-func run(line string, eieneErrors *eiene_errors.EieneErrors) {
-	tokens := scanner.NewScanner(line, eieneErrors, reader).ScanTokens()
+// Returns a function reading input one line at a time, ignoring the prompt.
+// Input is read a byte at a time so that commands reading the same input
+// get what follows the line. lineNumber counts the lines read.
+func lineReader(input io.Reader, lineNumber *int) scanner.ReaderFunc {
+	return func(prompt string) (string, error) {
+		line := []byte{}
+		c := make([]byte, 1)
+
+		for {
+			n, err := input.Read(c)
+			if n == 1 {
+				if c[0] == '\n' {
+					break
+				}
+				line = append(line, c[0])
+				continue
+			}
 
-	if !eieneErrors.HadError {
-		cmds := parser.NewParser(tokens).Parse()
+			if err == io.EOF && len(line) > 0 {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+		}
 
-		interpreter.NewInterpreter(cmds, eieneErrors).Interpret()
+		*lineNumber++
+		return string(line), nil
 	}
 }
 
-func main() {
+// Runs a single line. If the line ends inside an unclosed construct,
+// the next lines are read with read until the construct is closed.
+// Returns false if the command could not be read eg a parse error.
+func run(line string, read scanner.ReaderFunc, _interpreter *interpreter.Interpreter, eieneErrors *eiene_errors.EieneErrors) bool {
+	var tokens []token.Token
+
+	for {
+		_scanner := scanner.NewScanner(line, eieneErrors, read)
+		tokens = _scanner.ScanTokens()
+
+		if !eieneErrors.HadIncompleteInput {
+			break
+		}
+
+		nextLine, err := read(PS2)
+		if err == io.EOF {
+			eieneErrors.Report(eieneErrors.Errors[len(eieneErrors.Errors)-1])
+			return false
+		}
+		if err != nil {
+			return false
+		}
+		line = _scanner.Source() + "\n" + nextLine
+	}
+
+	if eieneErrors.HadError {
+		return false
+	}
+
+	cmds := parser.NewParser(tokens).Parse()
+	_interpreter.Interpret(cmds)
+
+	return true
+}
+
+// Runs the commands read from input without prompts, one after the other.
+// Returns the exit status of the last one, or 2 if a command could not be
+// read.
+func runNonInteractive(input io.Reader, _interpreter *interpreter.Interpreter, eieneErrors *eiene_errors.EieneErrors) int {
+	lineNumber := 0
+	read := lineReader(input, &lineNumber)
+
+	for {
+		eieneErrors.LineOffset = lineNumber
+
+		line, err := read("")
+		if err != nil {
+			break
+		}
+
+		if !run(line, read, _interpreter, eieneErrors) {
+			return 2
+		}
+
+		if eieneErrors.HadExitError {
+			break
+		}
+
+		eieneErrors.HadInterpreterError = false
+		eieneErrors.ResetErrors()
+	}
+
+	return _interpreter.Status()
+}
+
+// Reads commands from the terminal until exit or ^D.
+// Returns the exit status of the last command.
+func runInteractive() int {
 	reader, err := readline.New("$ ")
 	if err != nil {
 		color.Red(err.Error())
-		return
+		return 1
 	}
 	defer reader.Close()
 
 	ctrlCClicked := false
 	eieneErrors := eiene_errors.NewEieneErrors(true)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
+	_interpreter.Interactive = true
+	if err := _interpreter.EnableJobControl(); err != nil {
+		color.Red("eiene: no job control: %s", err)
+	}
 
 	for {
+		_interpreter.ReportDoneJobs()
 		line, err := reader.Readline()
 
 		switch err {
@@ -74,27 +175,75 @@ func main() {
 		case readline.ErrInterrupt:
 			{ // ^C
 				if ctrlCClicked {
-					return
+					return _interpreter.Status()
 				}
 				ctrlCClicked = true
 				fmt.Println("To exit, press Ctrl+C again or Ctrl+D")
 				continue
 			}
 		case io.EOF: // ^D
-			return
+			return _interpreter.Status()
 		default:
 			color.Red(err.Error())
-			return
+			return 1
 		}
 
-		run(line, eieneErrors)
+		run(line, readContinuation, _interpreter, eieneErrors)
 
 		if eieneErrors.HadExitError {
 			fmt.Println("Exiting eiene. See you soon ;)")
-			break
+			return _interpreter.Status()
 		}
 
 		eieneErrors.HadInterpreterError = false
 		eieneErrors.ResetErrors()
 	}
 }
+
+// Runs eiene interactively when started without arguments from a
+// terminal. Otherwise commands are run from a script file
+// (eiene script.eiene args...), a string (eiene -c 'cmd' name args...) or
+// the standard input.
+func main() {
+	args := os.Args[1:]
+
+	if len(args) == 0 && readline.IsTerminal(int(os.Stdin.Fd())) {
+		os.Exit(runInteractive())
+	}
+
+	color.NoColor = true
+	eieneErrors := eiene_errors.NewEieneErrors(true)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+	var input io.Reader = os.Stdin
+	switch {
+	case len(args) == 0:
+
+	case args[0] == "-c":
+		if len(args) < 2 {
+			eieneErrors.Report("-c: option requires an argument")
+			os.Exit(2)
+		}
+		input = strings.NewReader(args[1])
+		if len(args) > 2 {
+			_interpreter.SetPositional(args[2], args[3:])
+		}
+
+	default:
+		file, err := os.Open(args[0])
+		if err != nil {
+			var pathErr *fs.PathError
+			if errors.As(err, &pathErr) {
+				err = fmt.Errorf("%s: %w", pathErr.Path, pathErr.Err)
+			}
+			eieneErrors.Report(err.Error())
+			os.Exit(127)
+		}
+		input = bufio.NewReader(file)
+		eieneErrors.FileName = args[0]
+		_interpreter.SetPositional(args[0], args[1:])
+	}
+
+	status := runNonInteractive(input, _interpreter, eieneErrors)
+	os.Exit(status)
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"io"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/ivf8/simp-shell/pkg/eiene_errors"
+	"github.com/ivf8/simp-shell/pkg/interpreter"
 )
 
+// Reader used when no more input follows the command run
+func noInput(prompt string) (string, error) {
+	return "", io.EOF
+}
+
 func TestRunScanningAndParsing(t *testing.T) {
 	eieneErrors := eiene_errors.NewEieneErrors(false)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
 
 	parsingTests := []struct {
 		cmd              string
@@ -38,6 +48,17 @@ func TestRunScanningAndParsing(t *testing.T) {
 		{";&&", true},
 		{";", false},
 
+		// Pipelines
+		{"ls | cat", false},
+		{"ls | cat | wc -l && cd", false},
+		{"ls | | cat", true},
+		{"ls |; cat", true},
+
+		// Redirections
+		{"ls > /dev/null 2>&1", false},
+		{"ls >", true},
+		{"ls 2>; cd", true},
+
 		// Comments
 		{"#this is a comment", false},
 		{"cd #comment", false},
@@ -50,7 +71,7 @@ func TestRunScanningAndParsing(t *testing.T) {
 	for _, test := range parsingTests {
 		eieneErrors.ResetErrors()
 
-		run(test.cmd, eieneErrors)
+		run(test.cmd, noInput, _interpreter, eieneErrors)
 
 		if eieneErrors.HadError != test.expectedHadError {
 			t.Errorf(
@@ -63,6 +84,7 @@ func TestRunScanningAndParsing(t *testing.T) {
 
 func TestRunInterpreting(t *testing.T) {
 	eieneErrors := eiene_errors.NewEieneErrors(false)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
 
 	interpreterTests := []struct {
 		cmd           string
@@ -76,7 +98,7 @@ func TestRunInterpreting(t *testing.T) {
 		{"cd && ls", false},
 		{"cd || ls", false},
 		{"xoo9 && ls", true},
-		{"xoo9 || ls", false},
+		{"xoo9 || ls", true},
 
 		// Semicolon separated commands
 		{"cd ; ls", false},
@@ -88,14 +110,29 @@ func TestRunInterpreting(t *testing.T) {
 		{"cd #comment", false},
 		{"cd #&&&;||", false},
 
+		// Redirections
+		{"ls > /dev/null", false},
+		{"ls > /dev/null && cd", false},
+		{"ls -a < /dev/null > /dev/null 2>&1", false},
+
+		// Pipelines
+		{"ls | cat", false},
+		{"yes | head -1", false},
+		{"xoo9 | cat", false},
+		{"ls | xoo9", true},
+
+		// Redirection from a missing file
+		{"cat < unknown-file-001", true},
+
 		{"cd\\ls", true},
 		{"ls\\  \\.", true},
 	}
 
 	for _, test := range interpreterTests {
 		eieneErrors.ResetErrors()
+		eieneErrors.HadInterpreterError = false
 
-		run(test.cmd, eieneErrors)
+		run(test.cmd, noInput, _interpreter, eieneErrors)
 
 		if eieneErrors.HadInterpreterError != test.expectedError {
 			t.Errorf(
@@ -105,3 +142,76 @@ func TestRunInterpreting(t *testing.T) {
 		}
 	}
 }
+
+func TestRunNonInteractive(t *testing.T) {
+	tests := []struct {
+		script         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"echo one\necho two\n", "one\ntwo\n", 0},
+		{"echo one; false\n", "one\n", 1},
+		{"false &&\n  echo no\necho $?", "1\n", 0},
+		{"echo a \\\n  b", "a b\n", 0},
+		{"echo \"a\nb\"\n", "a\nb\n", 0},
+		{"# comment\n\n  echo x # y\n", "x\n", 0},
+		{"echo a\nexit 3\necho b\n", "a\n", 3},
+		{"sh -c 'exit 4'\n", "", 4},
+		{"echo a\necho ;; b\necho c\n", "a\n", 2},
+		{"echo \"unclosed\n", "", 2},
+		{"echo ${u:?oops}; echo notreached\necho notreached\n", "", 1},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_interpreter.Stdout = writer
+
+		status := runNonInteractive(strings.NewReader(test.script), _interpreter, eieneErrors)
+		writer.Close()
+
+		output, _ := io.ReadAll(reader)
+		reader.Close()
+
+		if string(output) != test.expectedOutput {
+			t.Errorf("Running %q output %q. Expected %q", test.script, output, test.expectedOutput)
+		}
+		if status != test.expectedStatus {
+			t.Errorf("Running %q exited with %d. Expected %d", test.script, status, test.expectedStatus)
+		}
+	}
+}
+
+func TestInputEndingInContinuationIsReported(t *testing.T) {
+	tests := []struct {
+		script        string
+		expectedError string
+	}{
+		{"echo a |", "eiene: Incomplete input, missing command after |\n"},
+		{"echo a &&\n", "eiene: Incomplete input, missing command after &&\n"},
+		{"echo a ||", "eiene: Incomplete input, missing command after ||\n"},
+		{"echo a \\", "eiene: Incomplete input, missing line after \\\n"},
+		{"echo a \\\n", "eiene: Incomplete input, missing line after \\\n"},
+	}
+
+	for _, test := range tests {
+		errorOutput := strings.Builder{}
+		eieneErrors := eiene_errors.NewEieneErrors(true)
+		eieneErrors.Output = &errorOutput
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		status := runNonInteractive(strings.NewReader(test.script), _interpreter, eieneErrors)
+
+		if errorOutput.String() != test.expectedError {
+			t.Errorf("Running %q reported %q. Expected %q", test.script, errorOutput.String(), test.expectedError)
+		}
+		if status != 2 {
+			t.Errorf("Running %q exited with %d. Expected 2", test.script, status)
+		}
+	}
+}
@@ -0,0 +1,573 @@
+package arithmetic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operators from the longest to the shortest so that the longest one
+// matching is used eg <<= before <<
+var OPERATORS = []string{
+	"<<=", ">>=",
+	"**", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"+=", "-=", "*=", "/=", "%=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "|", "^", "!", "~",
+	"?", ":", "=", "(", ")", ",",
+}
+
+// Assignment operators and the operator they apply eg + for +=
+var ASSIGNMENTS = map[string]string{
+	"=": "", "+=": "+", "-=": "-", "*=": "*", "/=": "/", "%=": "%",
+	"<<=": "<<", ">>=": ">>", "&=": "&", "^=": "^", "|=": "|",
+}
+
+// Binary operators by precedence, from the lowest to the highest
+var PRECEDENCE = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", ">", "<=", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// Maximum depth of variables whose values are expressions referring to
+// other variables
+const MAX_RECURSION = 1024
+
+// Variables used in expressions
+type Variables interface {
+	Get(name string) (string, bool)
+	Set(name, value string) error
+}
+
+// Error in an expression eg a division by 0
+type Error struct {
+	Message string
+	Token   string // Rest of the expression from where the error is
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (error token is \"%s\")", e.Message, e.Token)
+}
+
+type tokenKind int
+
+const (
+	numberToken tokenKind = iota
+	nameToken
+	operatorToken
+	endToken
+)
+
+type exprToken struct {
+	kind   tokenKind
+	text   string
+	offset int // Byte offset in the expression
+}
+
+type evaluator struct {
+	expr      string
+	tokens    []exprToken
+	current   int
+	variables Variables
+	depth     int
+}
+
+// Evaluates the integer expression expr eg i += 2 * (j - 1). Variables
+// are referred to by name and unset or empty ones are 0.
+func Evaluate(expr string, variables Variables) (int64, error) {
+	return evaluate(expr, variables, 0)
+}
+
+func evaluate(expr string, variables Variables, depth int) (int64, error) {
+	if depth > MAX_RECURSION {
+		return 0, &Error{Message: "expression recursion level exceeded", Token: expr}
+	}
+
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return 0, err
+	}
+
+	e := &evaluator{expr: expr, tokens: tokens, variables: variables, depth: depth}
+	if e.peek().kind == endToken {
+		return 0, nil
+	}
+
+	value, err := e.comma(true)
+	if err != nil {
+		return 0, err
+	}
+	if e.peek().kind != endToken {
+		return 0, e.error("syntax error in expression")
+	}
+
+	return value, nil
+}
+
+// Splits expr into numbers, names and operators
+func tokenize(expr string) ([]exprToken, error) {
+	tokens := []exprToken{}
+
+	n := 0
+	for n < len(expr) {
+		c := expr[n]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			n++
+
+		case isDigit(c):
+			start := n
+			for n < len(expr) && (isNameChar(expr[n]) || expr[n] == '#' || expr[n] == '@') {
+				n++
+			}
+			tokens = append(tokens, exprToken{numberToken, expr[start:n], start})
+
+		case isNameChar(c):
+			start := n
+			for n < len(expr) && isNameChar(expr[n]) {
+				n++
+			}
+			tokens = append(tokens, exprToken{nameToken, expr[start:n], start})
+
+		default:
+			operator := ""
+			for _, op := range OPERATORS {
+				if strings.HasPrefix(expr[n:], op) {
+					operator = op
+					break
+				}
+			}
+			if operator == "" {
+				return nil, &Error{Message: "syntax error: operand expected", Token: expr[n:]}
+			}
+			tokens = append(tokens, exprToken{operatorToken, operator, n})
+			n += len(operator)
+		}
+	}
+
+	return append(tokens, exprToken{endToken, "", len(expr)}), nil
+}
+
+// Expressions separated by commas, the value is that of the last one.
+// Operators with side effects only change variables if eval is true eg
+// the branch of a ternary not taken.
+func (e *evaluator) comma(eval bool) (int64, error) {
+	value, err := e.assignment(eval)
+	for err == nil && e.match(",") {
+		value, err = e.assignment(eval)
+	}
+	return value, err
+}
+
+// NAME = expr and the other assignment operators
+func (e *evaluator) assignment(eval bool) (int64, error) {
+	name := e.peek()
+	if name.kind == nameToken && e.current+1 < len(e.tokens) {
+		op := e.tokens[e.current+1]
+		if binary, ok := ASSIGNMENTS[op.text]; ok && op.kind == operatorToken {
+			e.current += 2
+
+			value, err := e.assignment(eval)
+			if err != nil || !eval {
+				return value, err
+			}
+
+			if binary != "" {
+				left, err := e.variable(name.text)
+				if err != nil {
+					return 0, err
+				}
+				value, err = e.apply(binary, left, value, op)
+				if err != nil {
+					return 0, err
+				}
+			}
+
+			return value, e.set(name.text, value)
+		}
+	}
+
+	return e.ternary(eval)
+}
+
+// cond ? expr : expr
+func (e *evaluator) ternary(eval bool) (int64, error) {
+	cond, err := e.binary(0, eval)
+	if err != nil || !e.match("?") {
+		return cond, err
+	}
+
+	then, err := e.comma(eval && cond != 0)
+	if err != nil {
+		return 0, err
+	}
+	if !e.match(":") {
+		return 0, e.error("`:' expected for conditional expression")
+	}
+	otherwise, err := e.assignment(eval && cond == 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if cond != 0 {
+		return then, nil
+	}
+	return otherwise, nil
+}
+
+// Binary operators from PRECEDENCE[level] and higher
+func (e *evaluator) binary(level int, eval bool) (int64, error) {
+	if level == len(PRECEDENCE) {
+		return e.power(eval)
+	}
+
+	left, err := e.binary(level+1, eval)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		op := e.peek()
+		if op.kind != operatorToken || !contains(PRECEDENCE[level], op.text) {
+			return left, nil
+		}
+		e.current++
+
+		// The right side of && and || is only run if needed
+		rightEval := eval
+		if op.text == "&&" {
+			rightEval = eval && left != 0
+		} else if op.text == "||" {
+			rightEval = eval && left == 0
+		}
+
+		right, err := e.binary(level+1, rightEval)
+		if err != nil {
+			return 0, err
+		}
+
+		// The value is not used
+		if !eval {
+			continue
+		}
+		if !rightEval {
+			left = boolean(op.text == "||")
+			continue
+		}
+		if left, err = e.apply(op.text, left, right, op); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// base ** exponent, right associative
+func (e *evaluator) power(eval bool) (int64, error) {
+	base, err := e.unary(eval)
+	if err != nil {
+		return 0, err
+	}
+
+	op := e.peek()
+	if !e.match("**") {
+		return base, nil
+	}
+
+	exponent, err := e.power(eval)
+	if err != nil || !eval {
+		return 0, err
+	}
+	return e.apply("**", base, exponent, op)
+}
+
+// Unary operators and increments eg -x, !x, ++x and x++
+func (e *evaluator) unary(eval bool) (int64, error) {
+	op := e.peek()
+
+	if op.kind == operatorToken && (op.text == "++" || op.text == "--") {
+		e.current++
+		name := e.advance()
+		if name.kind != nameToken {
+			return 0, e.errorAt(name, "syntax error: operand expected")
+		}
+		if !eval {
+			return 0, nil
+		}
+
+		value, err := e.variable(name.text)
+		if err != nil {
+			return 0, err
+		}
+		value += increment(op.text)
+		return value, e.set(name.text, value)
+	}
+
+	if op.kind == operatorToken && (op.text == "-" || op.text == "+" || op.text == "!" || op.text == "~") {
+		e.current++
+		value, err := e.unary(eval)
+		if err != nil {
+			return 0, err
+		}
+
+		switch op.text {
+		case "-":
+			return -value, nil
+		case "!":
+			return boolean(value == 0), nil
+		case "~":
+			return ^value, nil
+		}
+		return value, nil
+	}
+
+	return e.postfix(eval)
+}
+
+// Operands and x++, x--
+func (e *evaluator) postfix(eval bool) (int64, error) {
+	tok := e.advance()
+
+	switch tok.kind {
+	case numberToken:
+		return e.number(tok)
+
+	case nameToken:
+		next := e.peek()
+		if next.kind == operatorToken && (next.text == "++" || next.text == "--") {
+			e.current++
+			if !eval {
+				return 0, nil
+			}
+
+			value, err := e.variable(tok.text)
+			if err != nil {
+				return 0, err
+			}
+			return value, e.set(tok.text, value+increment(next.text))
+		}
+
+		if !eval {
+			return 0, nil
+		}
+		return e.variable(tok.text)
+
+	case operatorToken:
+		if tok.text == "(" {
+			value, err := e.comma(eval)
+			if err != nil {
+				return 0, err
+			}
+			if !e.match(")") {
+				return 0, e.error("missing `)'")
+			}
+			return value, nil
+		}
+	}
+
+	return 0, e.errorAt(tok, "syntax error: operand expected")
+}
+
+// Applies the binary operator op
+func (e *evaluator) apply(op string, left, right int64, tok exprToken) (int64, error) {
+	switch op {
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/", "%":
+		if right == 0 {
+			return 0, e.errorAt(e.tokens[e.current-1], "division by 0")
+		}
+		if op == "/" {
+			return left / right, nil
+		}
+		return left % right, nil
+	case "**":
+		if right < 0 {
+			return 0, e.errorAt(tok, "exponent less than 0")
+		}
+		result := int64(1)
+		for ; right > 0; right-- {
+			result *= left
+		}
+		return result, nil
+	case "<<":
+		return left << (uint64(right) & 63), nil
+	case ">>":
+		return left >> (uint64(right) & 63), nil
+	case "&":
+		return left & right, nil
+	case "|":
+		return left | right, nil
+	case "^":
+		return left ^ right, nil
+	case "&&":
+		return boolean(left != 0 && right != 0), nil
+	case "||":
+		return boolean(left != 0 || right != 0), nil
+	case "==":
+		return boolean(left == right), nil
+	case "!=":
+		return boolean(left != right), nil
+	case "<":
+		return boolean(left < right), nil
+	case ">":
+		return boolean(left > right), nil
+	case "<=":
+		return boolean(left <= right), nil
+	case ">=":
+		return boolean(left >= right), nil
+	}
+
+	return 0, e.errorAt(tok, "syntax error: invalid arithmetic operator")
+}
+
+// Returns the value of the variable name. A value that is not a number
+// is evaluated as an expression.
+func (e *evaluator) variable(name string) (int64, error) {
+	value, _ := e.variables.Get(name)
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	return evaluate(value, e.variables, e.depth+1)
+}
+
+func (e *evaluator) set(name string, value int64) error {
+	if err := e.variables.Set(name, strconv.FormatInt(value, 10)); err != nil {
+		return &Error{Message: name + ": " + err.Error(), Token: name}
+	}
+	return nil
+}
+
+// Parses a number, decimal, octal with a leading 0, hexadecimal with a
+// leading 0x or in any base from 2 to 64 as base#digits
+func (e *evaluator) number(tok exprToken) (int64, error) {
+	text := tok.text
+	base := int64(10)
+
+	if baseText, digits, found := strings.Cut(text, "#"); found {
+		b, err := strconv.ParseInt(baseText, 10, 64)
+		if err != nil || b < 2 || b > 64 {
+			return 0, e.errorAt(tok, "invalid arithmetic base")
+		}
+		base, text = b, digits
+	} else if len(text) > 2 && (text[:2] == "0x" || text[:2] == "0X") {
+		base, text = 16, text[2:]
+	} else if len(text) > 1 && text[0] == '0' {
+		base, text = 8, text[1:]
+	}
+
+	if text == "" {
+		return 0, e.errorAt(tok, "invalid number")
+	}
+
+	value := int64(0)
+	for _, c := range []byte(text) {
+		digit := digitValue(c, base)
+		if digit < 0 {
+			return 0, e.errorAt(tok, "invalid number")
+		}
+		if digit >= base {
+			return 0, e.errorAt(tok, "value too great for base")
+		}
+		value = value*base + digit
+	}
+
+	return value, nil
+}
+
+// Returns the value of the digit c in base. Digits above 9 are a-z, A-Z,
+// @ and _. Letters are case insensitive up to base 36.
+func digitValue(c byte, base int64) int64 {
+	switch {
+	case isDigit(c):
+		return int64(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int64(c-'a') + 10
+	case c >= 'A' && c <= 'Z':
+		if base <= 36 {
+			return int64(c-'A') + 10
+		}
+		return int64(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+	return -1
+}
+
+func (e *evaluator) peek() exprToken {
+	return e.tokens[e.current]
+}
+
+func (e *evaluator) advance() exprToken {
+	tok := e.tokens[e.current]
+	if tok.kind != endToken {
+		e.current++
+	}
+	return tok
+}
+
+// Advances if the current token is the operator op
+func (e *evaluator) match(op string) bool {
+	if tok := e.peek(); tok.kind == operatorToken && tok.text == op {
+		e.current++
+		return true
+	}
+	return false
+}
+
+// Returns an error at the current token
+func (e *evaluator) error(message string) error {
+	return e.errorAt(e.peek(), message)
+}
+
+// Returns an error at tok. The rest of the expression is shown from it.
+func (e *evaluator) errorAt(tok exprToken, message string) error {
+	return &Error{Message: message, Token: strings.TrimSpace(e.expr[tok.offset:])}
+}
+
+func increment(op string) int64 {
+	if op == "++" {
+		return 1
+	}
+	return -1
+}
+
+func boolean(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func contains(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
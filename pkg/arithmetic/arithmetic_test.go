@@ -0,0 +1,131 @@
+package arithmetic_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ivf8/simp-shell/pkg/arithmetic"
+)
+
+// Variables stored in a map
+type variables map[string]string
+
+func (v variables) Get(name string) (string, bool) {
+	value, ok := v[name]
+	return value, ok
+}
+
+func (v variables) Set(name, value string) error {
+	if name == "RO" {
+		return errors.New("readonly variable")
+	}
+	v[name] = value
+	return nil
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected int64
+	}{
+		{"", 0},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"7 / 2, 7 % 2", 1},
+		{"-7 / 2", -3},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", 4},
+		{"1 << 4 | 1", 17},
+		{"0xff & ~0xf ^ 1", 241},
+		{"010 + 2#101 + 16#1F + 64#_", 8 + 5 + 31 + 63},
+		{"3 > 2 && 2 >= 2 && 1 < 2 && 1 <= 1 && 1 == 1 && 1 != 2", 1},
+		{"0 || !5", 0},
+		{"x + y * 2", 5 + 2*2},
+		{"unset + 1", 1},
+		{"expr * 2", 6},
+		{"x > 3 ? 10 : 20", 10},
+		{"x < 3 ? 10 : y ? 30 : 40", 30},
+		{"0 && 1 / 0", 0},
+		{"1 || 1 / 0", 1},
+		{"1 ? 2 : 1 / 0", 2},
+	}
+
+	for _, test := range tests {
+		vars := variables{"x": "5", "y": "2", "expr": "x - y"}
+
+		result, err := arithmetic.Evaluate(test.expr, vars)
+		if err != nil {
+			t.Errorf("Evaluate(%q) failed with %v", test.expr, err)
+		} else if result != test.expected {
+			t.Errorf("Evaluate(%q) got %d. Expected %d", test.expr, result, test.expected)
+		}
+	}
+}
+
+func TestEvaluateAssignments(t *testing.T) {
+	tests := []struct {
+		expr          string
+		expected      int64
+		expectedValue string
+	}{
+		{"i = 3", 3, "3"},
+		{"i += 2", 7, "7"},
+		{"i -= 2", 3, "3"},
+		{"i *= 2 + 1", 15, "15"},
+		{"i <<= 1", 10, "10"},
+		{"i++", 5, "6"},
+		{"i--", 5, "4"},
+		{"++i", 6, "6"},
+		{"--i", 4, "4"},
+		{"j = i = 1", 1, "1"},
+		{"i++ + i++", 11, "7"},
+		{"0 && i++", 0, "5"},
+		{"1 ? i : i++", 5, "5"},
+	}
+
+	for _, test := range tests {
+		vars := variables{"i": "5"}
+
+		result, err := arithmetic.Evaluate(test.expr, vars)
+		if err != nil {
+			t.Errorf("Evaluate(%q) failed with %v", test.expr, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("Evaluate(%q) got %d. Expected %d", test.expr, result, test.expected)
+		}
+		if vars["i"] != test.expectedValue {
+			t.Errorf("Evaluate(%q) set i to %q. Expected %q", test.expr, vars["i"], test.expectedValue)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		expr          string
+		expectedError string
+	}{
+		{"1 / 0", `division by 0 (error token is "0")`},
+		{"5 % (2 - 2)", `division by 0 (error token is ")")`},
+		{"2 ** -1", `exponent less than 0 (error token is "** -1")`},
+		{"1 +", `syntax error: operand expected (error token is "")`},
+		{"1 2", `syntax error in expression (error token is "2")`},
+		{"(1", "missing `)' (error token is \"\")"},
+		{"1 ? 2", "`:' expected for conditional expression (error token is \"\")"},
+		{"09", `value too great for base (error token is "09")`},
+		{"1 $ 2", `syntax error: operand expected (error token is "$ 2")`},
+		{"RO = 1", `RO: readonly variable (error token is "RO")`},
+		{"self", `expression recursion level exceeded (error token is "self")`},
+	}
+
+	for _, test := range tests {
+		vars := variables{"self": "self"}
+
+		_, err := arithmetic.Evaluate(test.expr, vars)
+		if err == nil {
+			t.Errorf("Evaluate(%q) was expected to fail", test.expr)
+		} else if err.Error() != test.expectedError {
+			t.Errorf("Evaluate(%q) failed with %q. Expected %q", test.expr, err.Error(), test.expectedError)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Characters that need quoting for a word to be read back as one word
+const SPECIAL_WORD_CHARS = " \t\n'\"\\$`&|;<>()#*?[]~{}"
+
+// Formats commands back into source text eg to show the command of a job.
+type AstFormatter struct {
+	cmdList []Cmd
+}
+
+func NewAstFormatter(cmdList []Cmd) *AstFormatter {
+	return &AstFormatter{
+		cmdList: cmdList,
+	}
+}
+
+func (a AstFormatter) Format() string {
+	cmds := []string{}
+
+	for _, cmd := range a.cmdList {
+		cmds = append(cmds, cmd.Accept(a).(string))
+	}
+
+	return strings.Join(cmds, "; ")
+}
+
+func (a AstFormatter) VisitBackgroundCmd(cmd *BackgroundCmd) any {
+	return cmd.Cmd.Accept(a).(string) + " &"
+}
+
+func (a AstFormatter) VisitLogicalCmd(cmd *LogicalCmd) any {
+	return cmd.Left.Accept(a).(string) + " " + cmd.Operator.Lexeme + " " + cmd.Right.Accept(a).(string)
+}
+
+func (a AstFormatter) VisitPipelineCmd(cmd *PipelineCmd) any {
+	stages := []string{}
+
+	for _, stage := range cmd.Cmds {
+		stages = append(stages, stage.Accept(a).(string))
+	}
+
+	return strings.Join(stages, " | ")
+}
+
+func (a AstFormatter) VisitPrimaryCmd(cmd *PrimaryCmd) any {
+	words := []string{}
+
+	for _, assignment := range cmd.Assignments {
+		words = append(words, word(assignment))
+	}
+
+	if cmd.ProgramName.Type != "" {
+		words = append(words, word(cmd.ProgramName))
+	}
+
+	for _, arg := range cmd.Arguments {
+		words = append(words, word(arg))
+	}
+
+	for _, redirection := range cmd.Redirections {
+		words = append(words, redirection.Operator.Lexeme+word(redirection.Target))
+	}
+
+	return strings.Join(words, " ")
+}
+
+func (a AstFormatter) VisitArithmeticCmd(cmd *ArithmeticCmd) any {
+	return "((" + cmd.Expression.Lexeme + "))"
+}
+
+func (a AstFormatter) VisitIfCmd(cmd *IfCmd) any {
+	words := []string{}
+
+	for n, clause := range cmd.Clauses {
+		if n == 0 {
+			words = append(words, "if")
+		} else {
+			words = append(words, "elif")
+		}
+		words = append(words, a.list(clause.Condition), "then", a.list(clause.Body))
+	}
+
+	if cmd.Else != nil {
+		words = append(words, "else", a.list(cmd.Else))
+	}
+
+	words = append(words, "fi")
+	return strings.Join(words, " ") + a.redirections(cmd.Redirections)
+}
+
+func (a AstFormatter) VisitWhileCmd(cmd *WhileCmd) any {
+	words := []string{cmd.Keyword.Lexeme, a.list(cmd.Condition), "do", a.list(cmd.Body), "done"}
+	return strings.Join(words, " ") + a.redirections(cmd.Redirections)
+}
+
+func (a AstFormatter) VisitForCmd(cmd *ForCmd) any {
+	words := []string{"for", word(cmd.Name)}
+	if cmd.Words != nil {
+		words = append(words, "in")
+		for _, w := range cmd.Words {
+			words = append(words, word(w))
+		}
+	}
+
+	words[len(words)-1] += ";"
+	words = append(words, "do", a.list(cmd.Body), "done")
+	return strings.Join(words, " ") + a.redirections(cmd.Redirections)
+}
+
+func (a AstFormatter) VisitArithmeticForCmd(cmd *ArithmeticForCmd) any {
+	words := []string{"for", "((" + cmd.Expression.Lexeme + "));", "do", a.list(cmd.Body), "done"}
+	return strings.Join(words, " ") + a.redirections(cmd.Redirections)
+}
+
+func (a AstFormatter) VisitCaseCmd(cmd *CaseCmd) any {
+	words := []string{"case", word(cmd.Word), "in"}
+
+	for _, clause := range cmd.Clauses {
+		patterns := []string{}
+		for _, pattern := range clause.Patterns {
+			patterns = append(patterns, word(pattern))
+		}
+
+		terminator := clause.Terminator.Lexeme
+		if terminator == "" {
+			terminator = ";;"
+		}
+
+		// The ; ending the last command is part of the terminator eg a) ls;;
+		words = append(words, strings.Join(patterns, "|")+")")
+		if len(clause.Body) > 0 {
+			words = append(words, strings.TrimSuffix(a.list(clause.Body), ";")+terminator)
+		} else {
+			words = append(words, terminator)
+		}
+	}
+
+	words = append(words, "esac")
+	return strings.Join(words, " ") + a.redirections(cmd.Redirections)
+}
+
+func (a AstFormatter) VisitGroupCmd(cmd *GroupCmd) any {
+	return "{ " + a.list(cmd.Body) + " }" + a.redirections(cmd.Redirections)
+}
+
+func (a AstFormatter) VisitFunctionCmd(cmd *FunctionCmd) any {
+	return word(cmd.Name) + "() " + cmd.Body.Accept(a).(string)
+}
+
+// Formats the commands of a compound command, each one ended by ; unless
+// it is run in the background eg true; sleep 1 &
+func (a AstFormatter) list(cmds []Cmd) string {
+	formatted := []string{}
+
+	for _, cmd := range cmds {
+		if _, background := cmd.(*BackgroundCmd); background {
+			formatted = append(formatted, cmd.Accept(a).(string))
+		} else {
+			formatted = append(formatted, cmd.Accept(a).(string)+";")
+		}
+	}
+
+	return strings.Join(formatted, " ")
+}
+
+// Formats the redirections of a compound command eg fi >out
+func (a AstFormatter) redirections(redirections []Redirection) string {
+	formatted := strings.Builder{}
+
+	for _, redirection := range redirections {
+		formatted.WriteString(" " + redirection.Operator.Lexeme + word(redirection.Target))
+	}
+
+	return formatted.String()
+}
+
+// Returns a word as it was written, or quoted if it was not read from
+// source.
+func word(tok token.Token) string {
+	if tok.Raw != "" {
+		return tok.Raw
+	}
+
+	return quote(tok.Lexeme)
+}
+
+// Encloses word in single quotes if it has characters that would be
+// interpreted by the shell.
+func quote(word string) string {
+	if word != "" && !strings.ContainsAny(word, SPECIAL_WORD_CHARS) {
+		return word
+	}
+
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
@@ -30,6 +30,11 @@ func (a AstPrinter) SPrint() string {
 	return s.String()
 }
 
+// Print a BackgroundCmd enclosed in ()
+func (a AstPrinter) VisitBackgroundCmd(cmd *BackgroundCmd) any {
+	return " (" + cmd.Cmd.Accept(a).(string) + "&)"
+}
+
 // Print a LogicalCmd enclosed in ()
 func (a AstPrinter) VisitLogicalCmd(cmd *LogicalCmd) any {
 	logicalCmdBuilder := strings.Builder{}
@@ -45,10 +50,33 @@ func (a AstPrinter) VisitLogicalCmd(cmd *LogicalCmd) any {
 	return logicalCmdBuilder.String()
 }
 
+// Print a PipelineCmd enclosed in ()
+func (a AstPrinter) VisitPipelineCmd(cmd *PipelineCmd) any {
+	pipelineCmdBuilder := strings.Builder{}
+
+	pipelineCmdBuilder.WriteString(" (")
+
+	for n, stage := range cmd.Cmds {
+		if n > 0 {
+			pipelineCmdBuilder.WriteString("|")
+		}
+		pipelineCmdBuilder.WriteString(stage.Accept(a).(string))
+	}
+
+	pipelineCmdBuilder.WriteString(")")
+
+	return pipelineCmdBuilder.String()
+}
+
 // Print a PrimaryCmd
 func (a AstPrinter) VisitPrimaryCmd(cmd *PrimaryCmd) any {
 	primaryCmdBuilder := strings.Builder{}
 	primaryCmdBuilder.WriteString(" ")
+
+	for _, assignment := range cmd.Assignments {
+		primaryCmdBuilder.WriteString(assignment.Lexeme + " ")
+	}
+
 	primaryCmdBuilder.WriteString(cmd.ProgramName.Lexeme)
 	primaryCmdBuilder.WriteString(" ")
 
@@ -56,5 +84,160 @@ func (a AstPrinter) VisitPrimaryCmd(cmd *PrimaryCmd) any {
 		primaryCmdBuilder.WriteString(" " + arg.Lexeme)
 	}
 
+	for _, redirection := range cmd.Redirections {
+		primaryCmdBuilder.WriteString(" " + redirection.Operator.Lexeme + redirection.Target.Lexeme)
+	}
+
 	return primaryCmdBuilder.String()
 }
+
+// Print an ArithmeticCmd
+func (a AstPrinter) VisitArithmeticCmd(cmd *ArithmeticCmd) any {
+	return " ((" + cmd.Expression.Lexeme + "))"
+}
+
+// Print an IfCmd enclosed in ()
+func (a AstPrinter) VisitIfCmd(cmd *IfCmd) any {
+	ifCmdBuilder := strings.Builder{}
+
+	ifCmdBuilder.WriteString(" (")
+
+	for n, clause := range cmd.Clauses {
+		if n == 0 {
+			ifCmdBuilder.WriteString("if")
+		} else {
+			ifCmdBuilder.WriteString(" elif")
+		}
+		ifCmdBuilder.WriteString(a.list(clause.Condition))
+		ifCmdBuilder.WriteString(" then")
+		ifCmdBuilder.WriteString(a.list(clause.Body))
+	}
+
+	if cmd.Else != nil {
+		ifCmdBuilder.WriteString(" else")
+		ifCmdBuilder.WriteString(a.list(cmd.Else))
+	}
+
+	for _, redirection := range cmd.Redirections {
+		ifCmdBuilder.WriteString(" " + redirection.Operator.Lexeme + redirection.Target.Lexeme)
+	}
+
+	ifCmdBuilder.WriteString(")")
+
+	return ifCmdBuilder.String()
+}
+
+// Print a WhileCmd enclosed in ()
+func (a AstPrinter) VisitWhileCmd(cmd *WhileCmd) any {
+	whileCmdBuilder := strings.Builder{}
+
+	whileCmdBuilder.WriteString(" (" + cmd.Keyword.Lexeme)
+	whileCmdBuilder.WriteString(a.list(cmd.Condition))
+	whileCmdBuilder.WriteString(" do")
+	whileCmdBuilder.WriteString(a.list(cmd.Body))
+
+	for _, redirection := range cmd.Redirections {
+		whileCmdBuilder.WriteString(" " + redirection.Operator.Lexeme + redirection.Target.Lexeme)
+	}
+
+	whileCmdBuilder.WriteString(")")
+
+	return whileCmdBuilder.String()
+}
+
+// Print a ForCmd enclosed in ()
+func (a AstPrinter) VisitForCmd(cmd *ForCmd) any {
+	forCmdBuilder := strings.Builder{}
+
+	forCmdBuilder.WriteString(" (for " + cmd.Name.Lexeme)
+	if cmd.Words != nil {
+		forCmdBuilder.WriteString(" in")
+		for _, word := range cmd.Words {
+			forCmdBuilder.WriteString(" " + word.Lexeme)
+		}
+	}
+	forCmdBuilder.WriteString(" do")
+	forCmdBuilder.WriteString(a.list(cmd.Body))
+
+	for _, redirection := range cmd.Redirections {
+		forCmdBuilder.WriteString(" " + redirection.Operator.Lexeme + redirection.Target.Lexeme)
+	}
+
+	forCmdBuilder.WriteString(")")
+
+	return forCmdBuilder.String()
+}
+
+// Print an ArithmeticForCmd enclosed in ()
+func (a AstPrinter) VisitArithmeticForCmd(cmd *ArithmeticForCmd) any {
+	forCmdBuilder := strings.Builder{}
+
+	forCmdBuilder.WriteString(" (for ((" + cmd.Expression.Lexeme + ")) do")
+	forCmdBuilder.WriteString(a.list(cmd.Body))
+
+	for _, redirection := range cmd.Redirections {
+		forCmdBuilder.WriteString(" " + redirection.Operator.Lexeme + redirection.Target.Lexeme)
+	}
+
+	forCmdBuilder.WriteString(")")
+
+	return forCmdBuilder.String()
+}
+
+// Print a CaseCmd enclosed in ()
+func (a AstPrinter) VisitCaseCmd(cmd *CaseCmd) any {
+	caseCmdBuilder := strings.Builder{}
+
+	caseCmdBuilder.WriteString(" (case " + cmd.Word.Lexeme + " in")
+
+	for _, clause := range cmd.Clauses {
+		patterns := []string{}
+		for _, pattern := range clause.Patterns {
+			patterns = append(patterns, pattern.Lexeme)
+		}
+		caseCmdBuilder.WriteString(" " + strings.Join(patterns, "|") + ")")
+		caseCmdBuilder.WriteString(a.list(clause.Body))
+		caseCmdBuilder.WriteString(" " + clause.Terminator.Lexeme)
+	}
+
+	for _, redirection := range cmd.Redirections {
+		caseCmdBuilder.WriteString(" " + redirection.Operator.Lexeme + redirection.Target.Lexeme)
+	}
+
+	caseCmdBuilder.WriteString(")")
+
+	return caseCmdBuilder.String()
+}
+
+// Print a GroupCmd enclosed in ()
+func (a AstPrinter) VisitGroupCmd(cmd *GroupCmd) any {
+	groupCmdBuilder := strings.Builder{}
+
+	groupCmdBuilder.WriteString(" ({")
+	groupCmdBuilder.WriteString(a.list(cmd.Body))
+
+	for _, redirection := range cmd.Redirections {
+		groupCmdBuilder.WriteString(" " + redirection.Operator.Lexeme + redirection.Target.Lexeme)
+	}
+
+	groupCmdBuilder.WriteString(")")
+
+	return groupCmdBuilder.String()
+}
+
+// Print a FunctionCmd enclosed in ()
+func (a AstPrinter) VisitFunctionCmd(cmd *FunctionCmd) any {
+	return " (function " + cmd.Name.Lexeme + cmd.Body.Accept(a).(string) + ")"
+}
+
+// Print the commands of a compound command, each one followed by ;
+func (a AstPrinter) list(cmds []Cmd) string {
+	listBuilder := strings.Builder{}
+
+	for _, cmd := range cmds {
+		listBuilder.WriteString(cmd.Accept(a).(string))
+		listBuilder.WriteString(";")
+	}
+
+	return listBuilder.String()
+}
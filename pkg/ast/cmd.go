@@ -7,12 +7,45 @@ type Cmd interface {
 	// Method called in order to perform a specific operation
 	// on a certain Cmd as defined by the visitor's visiting method.
 	Accept(visitor CmdVisitor) any
+
+	// Position of the first token of the command in the source.
+	Pos() token.Position
 }
 
 // Interface implemented by any struct that interacts with Cmd.
 type CmdVisitor interface {
+	VisitBackgroundCmd(cmd *BackgroundCmd) any
 	VisitLogicalCmd(cmd *LogicalCmd) any
+	VisitPipelineCmd(cmd *PipelineCmd) any
 	VisitPrimaryCmd(cmd *PrimaryCmd) any
+	VisitArithmeticCmd(cmd *ArithmeticCmd) any
+	VisitIfCmd(cmd *IfCmd) any
+	VisitWhileCmd(cmd *WhileCmd) any
+	VisitForCmd(cmd *ForCmd) any
+	VisitArithmeticForCmd(cmd *ArithmeticForCmd) any
+	VisitCaseCmd(cmd *CaseCmd) any
+	VisitGroupCmd(cmd *GroupCmd) any
+	VisitFunctionCmd(cmd *FunctionCmd) any
+}
+
+// Command followed by & which is run without waiting for it to finish.
+type BackgroundCmd struct {
+	Cmd Cmd
+}
+
+func NewBackgroundCmd(cmd Cmd) *BackgroundCmd {
+	return &BackgroundCmd{
+		Cmd: cmd,
+	}
+}
+
+// Implement the Cmd interface
+func (b *BackgroundCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitBackgroundCmd(b)
+}
+
+func (b *BackgroundCmd) Pos() token.Position {
+	return b.Cmd.Pos()
 }
 
 // Command that uses the logical operators && or ||.
@@ -36,11 +69,46 @@ func (l *LogicalCmd) Accept(visitor CmdVisitor) any {
 	return visitor.VisitLogicalCmd(l)
 }
 
-// An individual command containing name of the program to run and Arguments
-// to pass to the program
+func (l *LogicalCmd) Pos() token.Position {
+	return l.Left.Pos()
+}
+
+// Commands delimited by |. The output of each command is
+// the input of the next one.
+type PipelineCmd struct {
+	Cmds []Cmd
+}
+
+func NewPipelineCmd(cmds []Cmd) *PipelineCmd {
+	return &PipelineCmd{
+		Cmds: cmds,
+	}
+}
+
+// Implement the Cmd interface
+func (p *PipelineCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitPipelineCmd(p)
+}
+
+func (p *PipelineCmd) Pos() token.Position {
+	return p.Cmds[0].Pos()
+}
+
+// Redirection of one of the file descriptors of a command eg 2>> log.txt
+type Redirection struct {
+	Operator token.Token // Starts with the file descriptor redirected if given
+	Target   token.Token // File or file descriptor redirected to
+}
+
+// An individual command containing name of the program to run, Arguments
+// to pass to the program and Redirections of its input and output.
+// ProgramName is empty for a command made of redirections or assignments
+// only eg > file or FOO=1
 type PrimaryCmd struct {
-	ProgramName token.Token
-	Arguments   []token.Token
+	Assignments  []token.Token // NAME=value words before the program name
+	ProgramName  token.Token
+	Arguments    []token.Token
+	Redirections []Redirection
 }
 
 func NewPrimaryCmd(programName token.Token, arguments []token.Token) *PrimaryCmd {
@@ -54,3 +122,227 @@ func NewPrimaryCmd(programName token.Token, arguments []token.Token) *PrimaryCmd
 func (p *PrimaryCmd) Accept(visitor CmdVisitor) any {
 	return visitor.VisitPrimaryCmd(p)
 }
+
+func (p *PrimaryCmd) Pos() token.Position {
+	if len(p.Assignments) > 0 {
+		return p.Assignments[0].Pos
+	}
+	if p.ProgramName.Type == "" && len(p.Redirections) > 0 {
+		return p.Redirections[0].Operator.Pos
+	}
+	return p.ProgramName.Pos
+}
+
+// Command ((expr)) evaluating an arithmetic expression. Its status is 0
+// if the expression is not 0.
+type ArithmeticCmd struct {
+	Expression token.Token
+}
+
+func NewArithmeticCmd(expression token.Token) *ArithmeticCmd {
+	return &ArithmeticCmd{
+		Expression: expression,
+	}
+}
+
+// Implement the Cmd interface.
+func (a *ArithmeticCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitArithmeticCmd(a)
+}
+
+func (a *ArithmeticCmd) Pos() token.Position {
+	return a.Expression.Pos
+}
+
+// Part of an if command: Body is run if Condition succeeds.
+type IfClause struct {
+	Condition []Cmd
+	Body      []Cmd
+}
+
+// Command if cond; then body; elif cond; then body; else body; fi. The
+// body of the first clause whose condition succeeds is run, Else if none
+// does.
+type IfCmd struct {
+	Keyword      token.Token // if
+	Clauses      []IfClause
+	Else         []Cmd
+	Redirections []Redirection
+}
+
+func NewIfCmd(keyword token.Token, clauses []IfClause, elseBody []Cmd) *IfCmd {
+	return &IfCmd{
+		Keyword: keyword,
+		Clauses: clauses,
+		Else:    elseBody,
+	}
+}
+
+// Implement the Cmd interface.
+func (i *IfCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitIfCmd(i)
+}
+
+func (i *IfCmd) Pos() token.Position {
+	return i.Keyword.Pos
+}
+
+// Command while cond; do body; done running body as long as cond
+// succeeds, or until it does if Keyword is until.
+type WhileCmd struct {
+	Keyword      token.Token // while or until
+	Condition    []Cmd
+	Body         []Cmd
+	Redirections []Redirection
+}
+
+func NewWhileCmd(keyword token.Token, condition []Cmd, body []Cmd) *WhileCmd {
+	return &WhileCmd{
+		Keyword:   keyword,
+		Condition: condition,
+		Body:      body,
+	}
+}
+
+// Implement the Cmd interface.
+func (w *WhileCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitWhileCmd(w)
+}
+
+func (w *WhileCmd) Pos() token.Position {
+	return w.Keyword.Pos
+}
+
+// Command for name in words; do body; done. Body is run with the variable
+// Name set to each field of the expanded words in turn.
+type ForCmd struct {
+	Keyword      token.Token
+	Name         token.Token
+	Words        []token.Token // nil without in, for the positional parameters
+	Body         []Cmd
+	Redirections []Redirection
+}
+
+func NewForCmd(keyword token.Token, name token.Token, words []token.Token, body []Cmd) *ForCmd {
+	return &ForCmd{
+		Keyword: keyword,
+		Name:    name,
+		Words:   words,
+		Body:    body,
+	}
+}
+
+// Implement the Cmd interface.
+func (f *ForCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitForCmd(f)
+}
+
+func (f *ForCmd) Pos() token.Position {
+	return f.Keyword.Pos
+}
+
+// Command for ((init; condition; update)); do body; done. The lexeme of
+// Expression holds the three arithmetic expressions delimited by ;
+type ArithmeticForCmd struct {
+	Keyword      token.Token
+	Expression   token.Token
+	Body         []Cmd
+	Redirections []Redirection
+}
+
+func NewArithmeticForCmd(keyword token.Token, expression token.Token, body []Cmd) *ArithmeticForCmd {
+	return &ArithmeticForCmd{
+		Keyword:    keyword,
+		Expression: expression,
+		Body:       body,
+	}
+}
+
+// Implement the Cmd interface.
+func (a *ArithmeticForCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitArithmeticForCmd(a)
+}
+
+func (a *ArithmeticForCmd) Pos() token.Position {
+	return a.Keyword.Pos
+}
+
+// Part of a case command: Body is run if the word matches one of Patterns.
+// Terminator is the ;; ;& or ;;& ending the clause, if any.
+type CaseClause struct {
+	Patterns   []token.Token
+	Body       []Cmd
+	Terminator token.Token
+}
+
+// Command case word in pattern|pattern) body;; esac. The body of the first
+// clause with a pattern matching word is run.
+type CaseCmd struct {
+	Keyword      token.Token
+	Word         token.Token
+	Clauses      []CaseClause
+	Redirections []Redirection
+}
+
+func NewCaseCmd(keyword token.Token, word token.Token, clauses []CaseClause) *CaseCmd {
+	return &CaseCmd{
+		Keyword: keyword,
+		Word:    word,
+		Clauses: clauses,
+	}
+}
+
+// Implement the Cmd interface.
+func (c *CaseCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitCaseCmd(c)
+}
+
+func (c *CaseCmd) Pos() token.Position {
+	return c.Keyword.Pos
+}
+
+// Commands grouped with { body; } eg to redirect them together.
+type GroupCmd struct {
+	Keyword      token.Token
+	Body         []Cmd
+	Redirections []Redirection
+}
+
+func NewGroupCmd(keyword token.Token, body []Cmd) *GroupCmd {
+	return &GroupCmd{
+		Keyword: keyword,
+		Body:    body,
+	}
+}
+
+// Implement the Cmd interface.
+func (g *GroupCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitGroupCmd(g)
+}
+
+func (g *GroupCmd) Pos() token.Position {
+	return g.Keyword.Pos
+}
+
+// Definition of a function eg name() { body; }. Body is a compound
+// command run when name is run as a command.
+type FunctionCmd struct {
+	Name token.Token
+	Body Cmd
+}
+
+func NewFunctionCmd(name token.Token, body Cmd) *FunctionCmd {
+	return &FunctionCmd{
+		Name: name,
+		Body: body,
+	}
+}
+
+// Implement the Cmd interface.
+func (f *FunctionCmd) Accept(visitor CmdVisitor) any {
+	return visitor.VisitFunctionCmd(f)
+}
+
+func (f *FunctionCmd) Pos() token.Position {
+	return f.Name.Pos
+}
@@ -0,0 +1,237 @@
+package brace
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Expands the braces in the word written as raw eg file{,.bak} gives file
+// and file.bak. Braces hold either words delimited by commas, which can
+// have braces themselves, or a sequence eg {1..10..2}, {01..10} or {a..e}.
+// Quoted and escaped braces and those of ${...} are left as they are, so
+// are braces holding neither.
+func Expand(raw string) []string {
+	runes := []rune(raw)
+
+	for n := 0; n < len(runes); n++ {
+		switch {
+		case runes[n] == '\\':
+			n++
+
+		case runes[n] == '\'' || runes[n] == '"' || runes[n] == '`':
+			n = quoteEnd(runes, n)
+
+		case runes[n] == '$' && n+1 < len(runes) && (runes[n+1] == '{' || runes[n+1] == '('):
+			n = groupEnd(runes, n+1)
+
+		case runes[n] == '{':
+			end, commas := braceEnd(runes, n)
+			if end < 0 {
+				continue
+			}
+
+			items := []string{}
+			if len(commas) > 0 {
+				start := n + 1
+				for _, comma := range append(commas, end) {
+					items = append(items, string(runes[start:comma]))
+					start = comma + 1
+				}
+			} else if sequence, ok := expandSequence(string(runes[n+1 : end])); ok {
+				items = sequence
+			} else {
+				continue
+			}
+
+			preamble, postamble := string(runes[:n]), string(runes[end+1:])
+			words := []string{}
+			for _, item := range items {
+				words = append(words, Expand(preamble+item+postamble)...)
+			}
+			return words
+		}
+	}
+
+	return []string{raw}
+}
+
+// Returns the words of the sequence expr eg 1..5, 10..1..3, 01..10 or
+// a..e, false if it is not one. Numbers starting with 0 are padded to the
+// same width.
+func expandSequence(expr string) ([]string, bool) {
+	parts := strings.Split(expr, "..")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, false
+	}
+
+	increment := 1
+	if len(parts) == 3 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, false
+		}
+		increment = max(n, -n, 1)
+	}
+
+	// Letters
+	first, last := []rune(parts[0]), []rune(parts[1])
+	if len(first) == 1 && len(last) == 1 && isLetter(first[0]) && isLetter(last[0]) {
+		words := []string{}
+		for _, c := range numbers(int(first[0]), int(last[0]), increment) {
+			// The words are expanded again, so the characters between Z
+			// and a eg \ and ` are escaped
+			word := string(rune(c))
+			if !isLetter(rune(c)) {
+				word = "\\" + word
+			}
+			words = append(words, word)
+		}
+		return words, true
+	}
+
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, false
+	}
+	end, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, false
+	}
+
+	width := 0
+	if isPadded(parts[0]) || isPadded(parts[1]) {
+		width = max(len(parts[0]), len(parts[1]))
+	}
+
+	words := []string{}
+	for _, n := range numbers(start, end, increment) {
+		words = append(words, pad(n, width))
+	}
+	return words, true
+}
+
+// Returns the numbers from start to end, counting down if end is less
+// than start.
+func numbers(start, end, increment int) []int {
+	list := []int{}
+	if start <= end {
+		for n := start; n <= end; n += increment {
+			list = append(list, n)
+		}
+	} else {
+		for n := start; n >= end; n -= increment {
+			list = append(list, n)
+		}
+	}
+	return list
+}
+
+// Returns the index of the } closing the brace at start and the indexes of
+// the commas delimiting its words. The index is -1 if it is not closed.
+func braceEnd(runes []rune, start int) (int, []int) {
+	commas := []int{}
+	depth := 0
+
+	for n := start + 1; n < len(runes); n++ {
+		switch {
+		case runes[n] == '\\':
+			n++
+
+		case runes[n] == '\'' || runes[n] == '"' || runes[n] == '`':
+			n = quoteEnd(runes, n)
+
+		case runes[n] == '$' && n+1 < len(runes) && (runes[n+1] == '{' || runes[n+1] == '('):
+			n = groupEnd(runes, n+1)
+
+		case runes[n] == '{':
+			depth++
+
+		case runes[n] == '}' && depth > 0:
+			depth--
+
+		case runes[n] == '}':
+			return n, commas
+
+		case runes[n] == ',' && depth == 0:
+			commas = append(commas, n)
+		}
+	}
+
+	return -1, nil
+}
+
+// Returns the index of the quote closing the one at start
+func quoteEnd(runes []rune, start int) int {
+	quote := runes[start]
+
+	n := start + 1
+	for ; n < len(runes) && runes[n] != quote; n++ {
+		switch {
+		case runes[n] == '\\' && quote != '\'':
+			n++
+
+		// Quotes inside substitutions eg "$(echo "a")" do not end the quote
+		case runes[n] == '$' && quote == '"' && n+1 < len(runes) && (runes[n+1] == '{' || runes[n+1] == '('):
+			n = groupEnd(runes, n+1)
+		}
+	}
+
+	return n
+}
+
+// Returns the index of the ) or } closing the one at start eg in ${A:-x}
+// or $(ls). Quoted ones are skipped over.
+func groupEnd(runes []rune, start int) int {
+	opening, closing := runes[start], '}'
+	if opening == '(' {
+		closing = ')'
+	}
+
+	depth := 0
+	n := start
+	for ; n < len(runes); n++ {
+		switch runes[n] {
+		case '\\':
+			n++
+
+		case '\'', '"', '`':
+			n = quoteEnd(runes, n)
+
+		case opening:
+			depth++
+
+		case closing:
+			depth--
+			if depth == 0 {
+				return n
+			}
+		}
+	}
+
+	return n
+}
+
+// Checks if the number s is written with leading zeros eg 01 or -05
+func isPadded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
+
+// Returns n padded with zeros to width characters, after its sign
+func pad(n, width int) string {
+	s := strconv.Itoa(max(n, -n))
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		width--
+	}
+
+	for len(s) < width {
+		s = "0" + s
+	}
+	return sign + s
+}
+
+func isLetter(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,52 @@
+package brace_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ivf8/simp-shell/pkg/brace"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{"src/{api,db,web}", []string{"src/api", "src/db", "src/web"}},
+		{"file{,.bak}", []string{"file", "file.bak"}},
+		{"{a,b}{1,2}", []string{"a1", "a2", "b1", "b2"}},
+		{"{a,{b,c}d}e", []string{"ae", "bde", "cde"}},
+		{"x{y{a,b}}", []string{"x{ya}", "x{yb}"}},
+		{"{1..4}", []string{"1", "2", "3", "4"}},
+		{"{3..1}", []string{"3", "2", "1"}},
+		{"{1..10..4}", []string{"1", "5", "9"}},
+		{"{10..1..-4}", []string{"10", "6", "2"}},
+		{"{01..10..3}", []string{"01", "04", "07", "10"}},
+		{"{-1..01}", []string{"-1", "00", "01"}},
+		{"{a..e..2}", []string{"a", "c", "e"}},
+		{"{C..A}", []string{"C", "B", "A"}},
+		{"{Z..b}", []string{"Z", `\[`, `\\`, `\]`, `\^`, `\_`, "\\`", "a", "b"}},
+		{"v{1..2}.{x,y}", []string{"v1.x", "v1.y", "v2.x", "v2.y"}},
+		{"'{a,b}'", []string{"'{a,b}'"}},
+		{`"{a,b}"x{1,2}`, []string{`"{a,b}"x1`, `"{a,b}"x2`}},
+		{`\{a,b}`, []string{`\{a,b}`}},
+		{`{a\,b}`, []string{`{a\,b}`}},
+		{"${A:-{a,b}}", []string{"${A:-{a,b}}"}},
+		{"$(echo {a,b})", []string{"$(echo {a,b})"}},
+		{`"$(echo "}")"{a,b}`, []string{`"$(echo "}")"a`, `"$(echo "}")"b`}},
+		{"{a}", []string{"{a}"}},
+		{"{}", []string{"{}"}},
+		{"{a,b", []string{"{a,b"}},
+		{"{1..a}", []string{"{1..a}"}},
+		{"{1..2..x}", []string{"{1..2..x}"}},
+		{"{,}", []string{"", ""}},
+	}
+
+	for _, test := range tests {
+		result := brace.Expand(test.raw)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Expand(%q) got %q. Expected %q", test.raw, result, test.expected)
+		}
+	}
+}
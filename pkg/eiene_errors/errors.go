@@ -2,17 +2,25 @@ package eiene_errors
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/ivf8/simp-shell/pkg/token"
 )
 
 type EieneErrors struct {
 	HadError            bool
 	HadInterpreterError bool
 	HadExitError        bool
+	HadIncompleteInput  bool // Input ended inside an unclosed construct
 	Errors              []string
+	FileName            string    // Name of the script being run, empty if interactive
+	LineOffset          int       // Lines of the script before the source being run
+	Output              io.Writer // Where errors are reported, stderr by default
 	printErrors         bool
+
+	sourceLines []string // Lines of the source being run, used to show where errors are
 }
 
 func NewEieneErrors(printErrors bool) *EieneErrors {
@@ -20,16 +28,40 @@ func NewEieneErrors(printErrors bool) *EieneErrors {
 		HadError:            false,
 		HadInterpreterError: false,
 		HadExitError:        false,
+		HadIncompleteInput:  false,
 		Errors:              []string{},
+		FileName:            "",
+		LineOffset:          0,
+		Output:              color.Error,
 		printErrors:         printErrors,
 	}
 }
 
-func (e *EieneErrors) ParseError(message string) {
+// Returns new errors for a command that runs alongside the current one
+// eg a stage of a pipeline. Errors are reported the same way but are
+// tracked separately.
+func (e *EieneErrors) Child() *EieneErrors {
+	child := NewEieneErrors(e.printErrors)
+	child.FileName = e.FileName
+	child.LineOffset = e.LineOffset
+	child.Output = e.Output
+	child.sourceLines = e.sourceLines
+
+	return child
+}
+
+// Sets the source that positions passed to the errors refer to.
+func (e *EieneErrors) SetSource(source string) {
+	e.sourceLines = strings.Split(source, "\n")
+}
+
+// Error in the syntax of the source. The line where it occurs is printed
+// with a caret under the offending token.
+func (e *EieneErrors) ParseError(pos token.Position, message string) {
 	errorMessage := "Parse error near " + message
 
 	e.Errors = append(e.Errors, errorMessage)
-	e.Report(errorMessage)
+	e.Report(e.location(pos) + errorMessage + e.caret(pos))
 }
 
 func (e *EieneErrors) NotImplementedError(message string) {
@@ -39,11 +71,28 @@ func (e *EieneErrors) NotImplementedError(message string) {
 	e.Report(errorMessage)
 }
 
-func (e *EieneErrors) InterpreterError(message string) {
+// Error raised when the input ends before a construct is closed eg an
+// unclosed quote. It is not reported since more input can complete it.
+func (e *EieneErrors) IncompleteInputError(message string) {
+	errorMessage := "Incomplete input, missing " + message
+
+	e.HadError = true
+	e.HadIncompleteInput = true
+	e.Errors = append(e.Errors, errorMessage)
+}
+
+// Error raised while running the command found at pos.
+func (e *EieneErrors) InterpreterError(pos token.Position, message string) {
 	errorMessage := strings.TrimPrefix(message, "exec: ")
 
 	e.HadInterpreterError = true
 	e.Errors = append(e.Errors, errorMessage)
+
+	// Interactive commands are run as soon as they are typed so their
+	// position is not reported
+	if e.FileName != "" {
+		errorMessage = e.location(pos) + errorMessage
+	}
 	e.Report(errorMessage)
 }
 
@@ -56,15 +105,49 @@ func (e *EieneErrors) ExitError() {
 func (e *EieneErrors) Report(message string) {
 	e.HadError = true
 	if e.printErrors {
-		color.Red("eiene: %s", message)
+		color.New(color.FgRed).Fprintf(e.Output, "eiene: %s\n", message)
 	}
 }
 
 func (e *EieneErrors) ResetErrors() {
 	e.HadError = false
+	e.HadIncompleteInput = false
 	e.Errors = []string{}
 }
 
+// Returns pos formatted as file:line:col: or line:col: if no file is run.
+func (e EieneErrors) location(pos token.Position) string {
+	line := e.LineOffset + pos.Line
+	if e.FileName == "" {
+		return fmt.Sprintf("%d:%d: ", line, pos.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d: ", e.FileName, line, pos.Column)
+}
+
+// Returns the source line at pos followed by a line with a caret under
+// the character at pos. Empty if the line is not known.
+func (e EieneErrors) caret(pos token.Position) string {
+	if pos.Line < 1 || pos.Line > len(e.sourceLines) {
+		return ""
+	}
+	line := e.sourceLines[pos.Line-1]
+
+	// Keep tabs so that the caret lines up with the source line
+	padding := strings.Builder{}
+	for i, c := range []rune(line) {
+		if i >= pos.Column-1 {
+			break
+		}
+		if c == '\t' {
+			padding.WriteRune('\t')
+		} else {
+			padding.WriteRune(' ')
+		}
+	}
+
+	return "\n" + line + "\n" + padding.String() + "^"
+}
+
 func (e EieneErrors) Error() string {
 	return fmt.Sprintf(
 		"%v",
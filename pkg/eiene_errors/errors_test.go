@@ -0,0 +1,81 @@
+package eiene_errors_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/ivf8/simp-shell/pkg/eiene_errors"
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Runs report with the errors printed to a buffer and returns the output.
+func reportHelper(e *eiene_errors.EieneErrors, report func()) string {
+	noColor := color.NoColor
+	defer func() {
+		color.NoColor = noColor
+	}()
+
+	buffer := bytes.Buffer{}
+	e.Output = &buffer
+	color.NoColor = true
+
+	report()
+	return buffer.String()
+}
+
+func TestParseErrorShowsCaret(t *testing.T) {
+	tests := []struct {
+		fileName, source string
+		pos              token.Position
+		expected         string
+	}{
+		{
+			"", "cd &&& ls",
+			token.Position{Line: 1, Column: 6, Offset: 5},
+			"eiene: 1:6: Parse error near &\ncd &&& ls\n     ^\n",
+		},
+		{
+			"script.eiene", "ls\n\tcd ;; ls\n",
+			token.Position{Line: 2, Column: 5, Offset: 7},
+			"eiene: script.eiene:2:5: Parse error near &\n\tcd ;; ls\n\t   ^\n",
+		},
+	}
+
+	for _, test := range tests {
+		e := eiene_errors.NewEieneErrors(true)
+		e.FileName = test.fileName
+		e.SetSource(test.source)
+
+		result := reportHelper(e, func() { e.ParseError(test.pos, "&") })
+
+		if result != test.expected {
+			t.Errorf("ParseError(%v) printed %q. Expected %q", test.pos, result, test.expected)
+		}
+		if e.Error() != "Parse error near &" {
+			t.Errorf("ParseError(%v) got error message %s", test.pos, e.Error())
+		}
+	}
+}
+
+func TestInterpreterErrorLocation(t *testing.T) {
+	pos := token.Position{Line: 120, Column: 3, Offset: 2000}
+
+	tests := []struct {
+		fileName, expected string
+	}{
+		{"", "eiene: not found\n"},
+		{"script.eiene", "eiene: script.eiene:120:3: not found\n"},
+	}
+
+	for _, test := range tests {
+		e := eiene_errors.NewEieneErrors(true)
+		e.FileName = test.fileName
+
+		result := reportHelper(e, func() { e.InterpreterError(pos, "exec: not found") })
+
+		if result != test.expected {
+			t.Errorf("InterpreterError(%v) printed %q. Expected %q", pos, result, test.expected)
+		}
+	}
+}
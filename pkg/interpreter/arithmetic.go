@@ -0,0 +1,48 @@
+package interpreter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ivf8/simp-shell/pkg/arithmetic"
+	"github.com/ivf8/simp-shell/pkg/ast"
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Evaluates the expression of a ((expr)) command. The status is 0 if it
+// is not 0, 1 if it is or the evaluation failed.
+func (i *Interpreter) VisitArithmeticCmd(cmd *ast.ArithmeticCmd) any {
+	i.substitutionStatus = -1
+	result, ok := i.evaluate([]rune(cmd.Expression.Lexeme), cmd.Pos())
+
+	i.status = 1
+	if ok && result != "0" {
+		i.status = 0
+	}
+
+	return nil
+}
+
+// Expands the parameters and command substitutions of the arithmetic
+// expression raw and evaluates it. Returns its value, false if the
+// expansion or the evaluation failed.
+func (i *Interpreter) evaluate(raw []rune, pos token.Position) (string, bool) {
+	expr, ok := i.expandString(raw, true, pos)
+	if !ok {
+		return "", false
+	}
+
+	value, err := arithmetic.Evaluate(expr, i.env)
+	if err != nil {
+		i.eieneErrors.InterpreterError(pos, strings.TrimSpace(expr)+": "+err.Error())
+		return "", false
+	}
+
+	return strconv.FormatInt(value, 10), true
+}
+
+// Checks if the substitution raw[start:end] eg $((1 + 2)) is an
+// arithmetic expansion rather than a command substitution.
+func isArithmeticExpansion(raw []rune, start, end int) bool {
+	return end-start >= 5 && raw[start+2] == '(' && raw[end-2] == ')'
+}
@@ -0,0 +1,385 @@
+package interpreter
+
+import (
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/ivf8/simp-shell/pkg/ast"
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Signals stopping the commands of loops, set by break and continue, and
+// of functions, set by return
+type controlSignal int
+
+const (
+	NO_SIGNAL       controlSignal = iota
+	BREAK_SIGNAL                  // Leave the loop
+	CONTINUE_SIGNAL               // Go to the next iteration of the loop
+	RETURN_SIGNAL                 // Leave the function
+)
+
+// Exit status of a command stopped with Ctrl-C
+const INTERRUPTED_STATUS = 128 + int(syscall.SIGINT)
+
+// Runs the body of the first clause whose condition succeeds, or the else
+// part if none does. The status is that of the body run, 0 if none is.
+func (i *Interpreter) VisitIfCmd(cmd *ast.IfCmd) any {
+	restore, ok := i.redirect(cmd.Redirections)
+	defer restore()
+
+	if !ok {
+		i.status = 1
+		return nil
+	}
+
+	for _, clause := range cmd.Clauses {
+		i.runList(clause.Condition)
+		if i.stopped() {
+			return nil
+		}
+
+		if i.status == 0 {
+			i.runList(clause.Body)
+			return nil
+		}
+	}
+
+	i.status = 0
+	if cmd.Else != nil {
+		i.runList(cmd.Else)
+	}
+
+	return nil
+}
+
+// Runs the commands grouped with { }, in the current shell
+func (i *Interpreter) VisitGroupCmd(cmd *ast.GroupCmd) any {
+	restore, ok := i.redirect(cmd.Redirections)
+	defer restore()
+
+	if !ok {
+		i.status = 1
+		return nil
+	}
+
+	i.runList(cmd.Body)
+	return nil
+}
+
+// Runs body as long as the condition succeeds, or until it does for an
+// until loop. The status is that of the last command of body run, 0 if
+// none is.
+func (i *Interpreter) VisitWhileCmd(cmd *ast.WhileCmd) any {
+	restore, ok := i.redirect(cmd.Redirections)
+	defer restore()
+
+	if !ok {
+		i.status = 1
+		return nil
+	}
+
+	i.loopDepth++
+	defer func() { i.loopDepth-- }()
+
+	until := cmd.Keyword.Type == token.UNTIL
+	status := 0
+
+	for {
+		i.runList(cmd.Condition)
+		if stop, next := i.loopSignal(); stop {
+			break
+		} else if next {
+			continue
+		}
+
+		if (i.status == 0) == until {
+			break
+		}
+
+		i.runList(cmd.Body)
+		status = i.status
+		if stop, _ := i.loopSignal(); stop {
+			break
+		}
+	}
+
+	i.endLoop(status)
+	return nil
+}
+
+// Runs body with the variable named by the loop set to each field of its
+// expanded words, or each positional parameter without in. The status is
+// that of the last command of body run, 0 if none is.
+func (i *Interpreter) VisitForCmd(cmd *ast.ForCmd) any {
+	restore, ok := i.redirect(cmd.Redirections)
+	defer restore()
+
+	if !ok {
+		i.status = 1
+		return nil
+	}
+
+	name := cmd.Name.Lexeme
+	if !isName(name) {
+		i.eieneErrors.InterpreterError(cmd.Name.Pos, "`"+name+"': not a valid identifier")
+		i.status = 1
+		return nil
+	}
+
+	values := i.positional
+	if cmd.Words != nil {
+		values, ok = i.expandWords(cmd.Words)
+		if !ok {
+			i.status = 1
+			return nil
+		}
+	}
+
+	i.loopDepth++
+	defer func() { i.loopDepth-- }()
+
+	status := 0
+	for _, value := range values {
+		if err := i.env.Set(name, value); err != nil {
+			i.eieneErrors.InterpreterError(cmd.Name.Pos, name+": "+err.Error())
+			status = 1
+			break
+		}
+
+		i.runList(cmd.Body)
+		status = i.status
+		if stop, _ := i.loopSignal(); stop {
+			break
+		}
+	}
+
+	i.endLoop(status)
+	return nil
+}
+
+// Evaluates the first expression of the loop, then runs body as long as
+// the second is not 0, evaluating the third after each iteration. Empty
+// expressions are skipped, an empty condition is always true. The status
+// is that of the last command of body run, 1 if an expression fails.
+func (i *Interpreter) VisitArithmeticForCmd(cmd *ast.ArithmeticForCmd) any {
+	restore, ok := i.redirect(cmd.Redirections)
+	defer restore()
+
+	if !ok {
+		i.status = 1
+		return nil
+	}
+
+	// The scanner checked there are 3 expressions
+	expressions := strings.Split(cmd.Expression.Lexeme, ";")
+	init, condition, update := expressions[0], expressions[1], expressions[2]
+	pos := cmd.Expression.Pos
+
+	i.loopDepth++
+	defer func() { i.loopDepth-- }()
+
+	if _, ok := i.evaluateOptional(init, pos); !ok {
+		i.status = 1
+		return nil
+	}
+
+	status := 0
+	for {
+		if result, ok := i.evaluateOptional(condition, pos); !ok {
+			status = 1
+			break
+		} else if result == "0" {
+			break
+		}
+
+		i.runList(cmd.Body)
+		status = i.status
+		if stop, _ := i.loopSignal(); stop {
+			break
+		}
+
+		if _, ok := i.evaluateOptional(update, pos); !ok {
+			status = 1
+			break
+		}
+	}
+
+	i.endLoop(status)
+	return nil
+}
+
+// Evaluates the arithmetic expression expr of a for loop. An empty one
+// gives 1.
+func (i *Interpreter) evaluateOptional(expr string, pos token.Position) (string, bool) {
+	if strings.TrimSpace(expr) == "" {
+		return "1", true
+	}
+	return i.evaluate([]rune(expr), pos)
+}
+
+// Runs the body of the first clause with a pattern matching the word of
+// the case command. A clause ended by ;& goes on with the body of the next
+// one, by ;;& with the next clause whose patterns match. The status is
+// that of the last command run, 0 if no pattern matches.
+func (i *Interpreter) VisitCaseCmd(cmd *ast.CaseCmd) any {
+	restore, ok := i.redirect(cmd.Redirections)
+	defer restore()
+
+	if !ok {
+		i.status = 1
+		return nil
+	}
+
+	word, ok := i.expandString([]rune(cmd.Word.Raw), false, cmd.Word.Pos)
+	if !ok {
+		i.status = 1
+		return nil
+	}
+
+	i.status = 0
+	next := false // Run the next body without matching
+	for _, clause := range cmd.Clauses {
+		if !next {
+			matched, ok := i.matchClause(clause, word)
+			if !ok {
+				i.status = 1
+				return nil
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		i.status = 0
+		i.runList(clause.Body)
+		if i.stopped() {
+			return nil
+		}
+
+		switch clause.Terminator.Type {
+		case token.SEMIAND:
+			next = true
+		case token.DSEMIAND:
+			next = false
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Checks if word matches one of the patterns of clause, expanded in turn
+// until one does. Returns false if an expansion failed.
+func (i *Interpreter) matchClause(clause ast.CaseClause, word string) (bool, bool) {
+	for _, _pattern := range clause.Patterns {
+		compiled, ok := i.expandPattern([]rune(_pattern.Raw), false, _pattern.Pos)
+		if !ok {
+			return false, false
+		}
+		if compiled.Match(word) {
+			return true, true
+		}
+	}
+
+	return false, true
+}
+
+// Handles the signal of break and continue once the commands of a loop
+// stopped. Returns whether the loop stops and whether it goes on to the
+// next iteration. A signal for outer loops or return also stops it.
+func (i *Interpreter) loopSignal() (bool, bool) {
+	if i.exiting || i.interrupted.Load() {
+		return true, false
+	}
+
+	signal := i.signal
+	if signal == NO_SIGNAL {
+		return false, false
+	}
+	if signal == RETURN_SIGNAL {
+		return true, false
+	}
+
+	i.signalLevels--
+	if i.signalLevels > 0 {
+		return true, false
+	}
+
+	i.signal = NO_SIGNAL
+	return signal == BREAK_SIGNAL, signal == CONTINUE_SIGNAL
+}
+
+// Sets the status of a loop that stopped to status, or that of Ctrl-C if
+// it was interrupted. exit and return keep theirs.
+func (i *Interpreter) endLoop(status int) {
+	switch {
+	case i.exiting || i.signal == RETURN_SIGNAL:
+	case i.interrupted.Load():
+		i.status = INTERRUPTED_STATUS
+	default:
+		i.status = status
+	}
+}
+
+// Execute break and continue builtin commands. They stop the commands of
+// the n innermost loops, 1 by default, and continue goes on to the next
+// iteration of the last one.
+func (i *Interpreter) loopControl(cmd *ast.PrimaryCmd, signal controlSignal, args []string) {
+	name := "break"
+	if signal == CONTINUE_SIGNAL {
+		name = "continue"
+	}
+
+	if i.loopDepth == 0 {
+		i.eieneErrors.InterpreterError(cmd.Pos(), name+": only meaningful in a `for', `while', or `until' loop")
+		return
+	}
+
+	if len(args) > 1 {
+		i.eieneErrors.InterpreterError(cmd.Pos(), name+": too many arguments")
+		i.status = 1
+		return
+	}
+
+	levels := 1
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		switch {
+		case err != nil:
+			i.eieneErrors.InterpreterError(cmd.Pos(), name+": "+args[0]+": numeric argument required")
+		case n < 1:
+			i.eieneErrors.InterpreterError(cmd.Pos(), name+": "+args[0]+": loop count out of range")
+		default:
+			levels = n
+		}
+
+		// An invalid count leaves all the loops, as in bash
+		if err != nil || n < 1 {
+			i.status = 1
+			signal, levels = BREAK_SIGNAL, i.loopDepth
+		}
+	}
+
+	i.signal = signal
+	i.signalLevels = min(levels, i.loopDepth)
+}
+
+// Runs the commands of a compound command one after the other, as part of
+// the current job. Stops at exit, break, continue, return or Ctrl-C.
+func (i *Interpreter) runList(cmds []ast.Cmd) {
+	for _, cmd := range cmds {
+		cmd.Accept(i)
+
+		if i.stopped() {
+			return
+		}
+	}
+}
+
+// Checks if the commands being run must stop eg after break or return
+func (i *Interpreter) stopped() bool {
+	return i.exiting || i.signal != NO_SIGNAL || i.interrupted.Load()
+}
@@ -0,0 +1,396 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ivf8/simp-shell/pkg/ast"
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Error returned when a readonly variable is assigned or unset
+var ErrReadonly = errors.New("readonly variable")
+
+// A shell variable
+type variable struct {
+	value    string
+	set      bool // Has a value, export NAME can mark a variable without one
+	exported bool // Passed to the commands run
+	readonly bool
+}
+
+// Shell variables. Exported ones make up the environment of the commands
+// run. Variables are looked up from the innermost scope outwards.
+type Environment struct {
+	variables map[string]*variable
+	parent    *Environment // Enclosing scope, nil for the global one
+}
+
+// Creates a global environment exporting the variables in environ, given
+// as NAME=value like os.Environ.
+func NewEnvironment(environ []string) *Environment {
+	e := &Environment{
+		variables: map[string]*variable{},
+		parent:    nil,
+	}
+
+	for _, entry := range environ {
+		name, value, found := strings.Cut(entry, "=")
+		if !found || name == "" {
+			continue
+		}
+		e.variables[name] = &variable{value: value, set: true, exported: true}
+	}
+
+	return e
+}
+
+// Returns a new scope enclosed by e eg the variables local to a function.
+func (e *Environment) NewScope() *Environment {
+	return &Environment{
+		variables: map[string]*variable{},
+		parent:    e,
+	}
+}
+
+// Returns a copy of e and its enclosing scopes. Changes to the copy do not
+// affect e eg variables set in a pipeline.
+func (e *Environment) Copy() *Environment {
+	copied := &Environment{
+		variables: make(map[string]*variable, len(e.variables)),
+		parent:    nil,
+	}
+	for name, v := range e.variables {
+		_v := *v
+		copied.variables[name] = &_v
+	}
+
+	if e.parent != nil {
+		copied.parent = e.parent.Copy()
+	}
+
+	return copied
+}
+
+// Returns the value of the variable name and whether it is set.
+func (e *Environment) Get(name string) (string, bool) {
+	v := e.lookup(name)
+	if v == nil || !v.set {
+		return "", false
+	}
+	return v.value, true
+}
+
+// Sets the variable name to value. A new variable is global unless a
+// scope declared it with Local.
+func (e *Environment) Set(name, value string) error {
+	v := e.declare(name)
+	if v.readonly {
+		return ErrReadonly
+	}
+
+	v.value = value
+	v.set = true
+	return nil
+}
+
+// Declares the variable name in the scope e, hiding any variable with the
+// same name in the enclosing scopes. It has no value until set.
+func (e *Environment) Local(name string) error {
+	if v := e.lookup(name); v != nil && v.readonly {
+		return ErrReadonly
+	}
+	if _, ok := e.variables[name]; !ok {
+		e.variables[name] = &variable{}
+	}
+	return nil
+}
+
+// Removes the variable name.
+func (e *Environment) Unset(name string) error {
+	for scope := e; scope != nil; scope = scope.parent {
+		v, ok := scope.variables[name]
+		if !ok {
+			continue
+		}
+		if v.readonly {
+			return ErrReadonly
+		}
+
+		delete(scope.variables, name)
+		return nil
+	}
+
+	return nil
+}
+
+// Marks the variable name to be passed to the commands run.
+func (e *Environment) Export(name string) {
+	e.declare(name).exported = true
+}
+
+// Marks the variable name so that it can no longer be set or unset.
+func (e *Environment) Readonly(name string) {
+	e.declare(name).readonly = true
+}
+
+// Checks if the variable name is exported
+func (e *Environment) IsExported(name string) bool {
+	v := e.lookup(name)
+	return v != nil && v.exported
+}
+
+// Checks if the variable name is readonly
+func (e *Environment) IsReadonly(name string) bool {
+	v := e.lookup(name)
+	return v != nil && v.readonly
+}
+
+// Returns the exported variables that are set, as NAME=value sorted by
+// name. Used as the environment of the commands run.
+func (e *Environment) Environ() []string {
+	environ := []string{}
+	for _, name := range e.Names() {
+		v := e.lookup(name)
+		if v.exported && v.set {
+			environ = append(environ, name+"="+v.value)
+		}
+	}
+
+	return environ
+}
+
+// Returns the names of the variables visible from e, sorted.
+func (e *Environment) Names() []string {
+	seen := map[string]bool{}
+	names := []string{}
+
+	for scope := e; scope != nil; scope = scope.parent {
+		for name := range scope.variables {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Returns the variable name from the innermost scope declaring it, nil
+// if none does.
+func (e *Environment) lookup(name string) *variable {
+	for scope := e; scope != nil; scope = scope.parent {
+		if v, ok := scope.variables[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// Returns the variable name, declaring it in the global scope without a
+// value if no scope declares it.
+func (e *Environment) declare(name string) *variable {
+	v := e.lookup(name)
+	if v == nil {
+		v = &variable{}
+		e.global().variables[name] = v
+	}
+	return v
+}
+
+// Returns the outermost scope
+func (e *Environment) global() *Environment {
+	scope := e
+	for scope.parent != nil {
+		scope = scope.parent
+	}
+	return scope
+}
+
+// Checks if name can be used as a variable name eg HOME or _var1
+func isName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for n, c := range name {
+		if !isNameChar(c) || (n == 0 && !isNameStart(c)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks if c can start a variable name
+func isNameStart(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Checks if c can be part of a variable name
+func isNameChar(c rune) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// Sets the variables of assignments eg FOO=1 in FOO=1 ls in a new scope
+// exported to the command run. The caller restores i.env once the command
+// is done. Returns false if a variable is readonly.
+func (i *Interpreter) assignTemporarily(assignments []token.Token) bool {
+	i.env = i.env.NewScope()
+
+	for _, assignment := range assignments {
+		name, value, ok := i.expandAssignment(assignment)
+		if !ok {
+			return false
+		}
+		if err := i.env.Local(name); err != nil {
+			i.eieneErrors.InterpreterError(assignment.Pos, name+": "+err.Error())
+			return false
+		}
+
+		i.env.Set(name, value)
+		i.env.Export(name)
+	}
+
+	return true
+}
+
+// Finds the program name in the directories of the PATH variable. Names
+// containing a slash are not looked up. Relative directories are found
+// from the working directory.
+func (i *Interpreter) lookPath(name string) (string, error) {
+	if strings.ContainsAny(name, "/"+string(filepath.Separator)) {
+		return name, nil
+	}
+
+	path, _ := i.env.Get("PATH")
+	for _, dir := range filepath.SplitList(path) {
+		// An empty directory is the current one
+		if dir == "" {
+			dir = "."
+		}
+		if file, err := exec.LookPath(filepath.Join(i.absPath(dir), name)); err == nil {
+			return file, nil
+		}
+	}
+
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// Execute export builtin command. Each argument is a variable to export,
+// set first if given as NAME=value. Without arguments the exported
+// variables are listed.
+func (i *Interpreter) export(cmd *ast.PrimaryCmd, args []string) {
+	if args, ok := i.listOption(cmd, "export", args); ok {
+		i.declareVariables(cmd, "export", args, i.env.Export, i.env.IsExported)
+	}
+}
+
+// Execute readonly builtin command. Each argument is a variable to make
+// readonly, set first if given as NAME=value. Without arguments the
+// readonly variables are listed.
+func (i *Interpreter) readonly(cmd *ast.PrimaryCmd, args []string) {
+	if args, ok := i.listOption(cmd, "readonly", args); ok {
+		i.declareVariables(cmd, "readonly", args, i.env.Readonly, i.env.IsReadonly)
+	}
+}
+
+// Removes the -p or -- option of export and readonly from args. Returns
+// false if another option is given.
+func (i *Interpreter) listOption(cmd *ast.PrimaryCmd, builtin string, args []string) ([]string, bool) {
+	if len(args) > 0 && (args[0] == "-p" || args[0] == "--") {
+		return args[1:], true
+	}
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		i.eieneErrors.InterpreterError(cmd.Pos(), builtin+": "+args[0]+": invalid option")
+		i.status = 2
+		return nil, false
+	}
+	return args, true
+}
+
+// Sets the variables given as NAME or NAME=value by export, readonly and
+// declare and marks them with mark. Lists the variables with has when no
+// variable is given. Options must have been removed from args.
+func (i *Interpreter) declareVariables(
+	cmd *ast.PrimaryCmd, builtin string, args []string,
+	mark func(string), has func(string) bool,
+) {
+	if len(args) == 0 {
+		for _, name := range i.env.Names() {
+			if !has(name) {
+				continue
+			}
+			if value, ok := i.env.Get(name); ok {
+				fmt.Fprintf(i.Stdout, "%s %s=%s\n", builtin, name, quoteValue(value))
+			} else {
+				fmt.Fprintf(i.Stdout, "%s %s\n", builtin, name)
+			}
+		}
+		return
+	}
+
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !isName(name) {
+			i.eieneErrors.InterpreterError(cmd.Pos(), builtin+": `"+arg+"': not a valid identifier")
+			i.status = 1
+			continue
+		}
+
+		if hasValue {
+			if err := i.env.Set(name, value); err != nil {
+				i.eieneErrors.InterpreterError(cmd.Pos(), name+": "+err.Error())
+				i.status = 1
+				continue
+			}
+		}
+		mark(name)
+	}
+}
+
+// Execute unset builtin command. Removes the variables named by args, or
+// the functions with -f. Without -v a function is removed if there is no
+// variable with its name.
+func (i *Interpreter) unset(cmd *ast.PrimaryCmd, args []string) {
+	option := ""
+	if len(args) > 0 && (args[0] == "-v" || args[0] == "-f") {
+		option = args[0]
+		args = args[1:]
+	} else if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	} else if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		i.eieneErrors.InterpreterError(cmd.Pos(), "unset: "+args[0]+": invalid option")
+		i.status = 2
+		return
+	}
+
+	for _, name := range args {
+		if option == "-f" || (option == "" && i.env.lookup(name) == nil && i.functions[name] != nil) {
+			delete(i.functions, name)
+			continue
+		}
+
+		if !isName(name) {
+			i.eieneErrors.InterpreterError(cmd.Pos(), "unset: `"+name+"': not a valid identifier")
+			i.status = 1
+			continue
+		}
+
+		if err := i.env.Unset(name); err != nil {
+			i.eieneErrors.InterpreterError(cmd.Pos(), "unset: "+name+": cannot unset: "+err.Error())
+			i.status = 1
+		}
+	}
+}
+
+// Returns value single quoted so that it can be read back by the shell
+func quoteValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,81 @@
+package interpreter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ivf8/simp-shell/pkg/interpreter"
+)
+
+func TestEnvironment(t *testing.T) {
+	env := interpreter.NewEnvironment([]string{"HOME=/home/eiene", "EMPTY=", "invalid"})
+
+	if value, ok := env.Get("HOME"); !ok || value != "/home/eiene" {
+		t.Errorf("Get(HOME) got (%q, %v). Expected (\"/home/eiene\", true)", value, ok)
+	}
+	if value, ok := env.Get("EMPTY"); !ok || value != "" {
+		t.Errorf("Get(EMPTY) got (%q, %v). Expected (\"\", true)", value, ok)
+	}
+	if _, ok := env.Get("invalid"); ok {
+		t.Errorf("Get(invalid) found a variable not given as NAME=value")
+	}
+
+	env.Set("SHELL_VAR", "1")
+	env.Export("EXPORTED_UNSET")
+	expectedEnviron := []string{"EMPTY=", "HOME=/home/eiene"}
+	if environ := env.Environ(); !reflect.DeepEqual(environ, expectedEnviron) {
+		t.Errorf("Environ() got %v. Expected %v", environ, expectedEnviron)
+	}
+
+	env.Export("SHELL_VAR")
+	env.Readonly("SHELL_VAR")
+	if err := env.Set("SHELL_VAR", "2"); err != interpreter.ErrReadonly {
+		t.Errorf("Set of a readonly variable got %v. Expected %v", err, interpreter.ErrReadonly)
+	}
+	if err := env.Unset("SHELL_VAR"); err != interpreter.ErrReadonly {
+		t.Errorf("Unset of a readonly variable got %v. Expected %v", err, interpreter.ErrReadonly)
+	}
+
+	copied := env.Copy()
+	copied.Set("HOME", "/")
+	if value, _ := env.Get("HOME"); value != "/home/eiene" {
+		t.Errorf("Set on a copy changed the variable to %q", value)
+	}
+
+	expectedEnviron = []string{"EMPTY=", "HOME=/home/eiene", "SHELL_VAR=1"}
+	if environ := env.Environ(); !reflect.DeepEqual(environ, expectedEnviron) {
+		t.Errorf("Environ() got %v. Expected %v", environ, expectedEnviron)
+	}
+}
+
+func TestEnvironmentScopes(t *testing.T) {
+	global := interpreter.NewEnvironment([]string{"A=global", "B=global"})
+	scope := global.NewScope()
+
+	scope.Local("A")
+	scope.Set("A", "local")
+	scope.Set("B", "set in scope")
+	scope.Set("C", "new")
+
+	tests := []struct {
+		env           *interpreter.Environment
+		name          string
+		expectedValue string
+	}{
+		{scope, "A", "local"},
+		{global, "A", "global"},
+		{global, "B", "set in scope"},
+		{global, "C", "new"},
+	}
+
+	for _, test := range tests {
+		if value, _ := test.env.Get(test.name); value != test.expectedValue {
+			t.Errorf("Get(%s) got %q. Expected %q", test.name, value, test.expectedValue)
+		}
+	}
+
+	scope.Unset("A")
+	if value, _ := scope.Get("A"); value != "global" {
+		t.Errorf("Get(A) after unsetting the local got %q. Expected \"global\"", value)
+	}
+}
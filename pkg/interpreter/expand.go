@@ -0,0 +1,400 @@
+package interpreter
+
+import (
+	"strings"
+
+	"github.com/ivf8/simp-shell/pkg/ast"
+	"github.com/ivf8/simp-shell/pkg/brace"
+	"github.com/ivf8/simp-shell/pkg/pattern"
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Fields a word expands to. Most words expand to a single field but "$@"
+// gives one for each positional parameter and an unquoted empty expansion
+// gives none.
+type fields struct {
+	list      []string
+	current   strings.Builder
+	started   bool // The current field exists even if it is empty
+	delimited bool // The last field was ended by IFS white space
+
+	split      bool   // Unquoted expansions are split into fields
+	ifs        string // Characters splitting fields, $IFS
+	splitText  bool   // Unquoted text is split too eg word in ${VAR:-word}
+	pattern    bool   // Quoted characters are escaped to only match themselves
+	glob       bool   // Same as pattern, unquoted back slashes are escaped too
+	assignment bool   // Tildes after : are expanded too eg in PATH=~/bin:~/go/bin
+	positional int    // Number of "$@" expanded, they can give no field
+}
+
+// Appends unquoted s to the current field. Empty strings do not start a
+// field.
+func (f *fields) write(s string) {
+	if s == "" {
+		return
+	}
+	if f.glob {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	f.current.WriteString(s)
+	f.started = true
+	f.delimited = false
+}
+
+// Appends quoted s to the current field, starting it even if s is empty.
+func (f *fields) writeQuoted(s string) {
+	if f.pattern || f.glob {
+		s = pattern.Escape(s)
+	}
+	f.current.WriteString(s)
+	f.started = true
+	f.delimited = false
+}
+
+// Appends s to the current field, quoted or not.
+func (f *fields) writeValue(s string, quoted bool) {
+	if quoted {
+		f.writeQuoted(s)
+	} else {
+		f.write(s)
+	}
+}
+
+// Appends the result of an expansion to the current field. Unless quoted,
+// it is split into fields at the characters of IFS. White space ones are
+// skipped at the start and end and several of them delimit a single field
+// while each of the others delimits one, which can be empty eg a,,b.
+func (f *fields) writeSplit(s string, quoted bool) {
+	if quoted || !f.split {
+		f.writeValue(s, quoted)
+		return
+	}
+
+	for _, c := range s {
+		switch {
+		case !strings.ContainsRune(f.ifs, c):
+			f.write(string(c))
+
+		case c == ' ' || c == '\t' || c == '\n':
+			if f.started {
+				f.end()
+				f.delimited = true
+			}
+
+		// White space around it is part of the same delimiter
+		case f.delimited:
+			f.delimited = false
+
+		default:
+			f.started = true
+			f.end()
+		}
+	}
+}
+
+// Ends the current field. Following writes start a new one.
+func (f *fields) end() {
+	if f.started {
+		f.list = append(f.list, f.current.String())
+	}
+	f.current.Reset()
+	f.started = false
+}
+
+// Returns the fields joined by spaces
+func (f *fields) String() string {
+	f.end()
+	return strings.Join(f.list, " ")
+}
+
+// Expands the braces, tildes, parameters, command substitutions and
+// arithmetic in word, removes its quotes and replaces patterns with the files they match.
+// Returns the fields the word expands to, false if an expansion failed.
+// Words not read from source are taken as they are.
+func (i *Interpreter) expandWord(word token.Token) ([]string, bool) {
+	if word.Raw == "" {
+		return []string{word.Lexeme}, true
+	}
+
+	list := []string{}
+	for _, raw := range brace.Expand(word.Raw) {
+		value := &fields{split: true, ifs: i.ifs(), glob: true}
+		if !i.expand([]rune(raw), value, false, word.Pos) {
+			return nil, false
+		}
+
+		value.end()
+		list = append(list, value.list...)
+	}
+
+	return i.glob(list, word.Pos)
+}
+
+// Expands each word in words. Returns the fields they expand to, false if
+// an expansion failed.
+func (i *Interpreter) expandWords(words []token.Token) ([]string, bool) {
+	var list []string
+	for _, word := range words {
+		fields, ok := i.expandWord(word)
+		if !ok {
+			return nil, false
+		}
+		list = append(list, fields...)
+	}
+	return list, true
+}
+
+// Expands the program name and arguments of cmd. The arguments of
+// declaration builtins written as NAME=value are expanded like assignment
+// words, without being split into fields or matched against files.
+func (i *Interpreter) expandCommand(cmd *ast.PrimaryCmd) ([]string, bool) {
+	words, ok := i.expandWord(cmd.ProgramName)
+	if !ok {
+		return nil, false
+	}
+	declaration := DECLARATION_BUILTINS_MAP[cmd.ProgramName.Raw]
+
+	for _, arg := range cmd.Arguments {
+		if name, _, found := strings.Cut(arg.Raw, "="); declaration && found && isName(name) {
+			name, value, ok := i.expandAssignment(arg)
+			if !ok {
+				return nil, false
+			}
+			words = append(words, name+"="+value)
+			continue
+		}
+
+		fields, ok := i.expandWord(arg)
+		if !ok {
+			return nil, false
+		}
+		words = append(words, fields...)
+	}
+
+	return words, true
+}
+
+// Expands the value of an assignment word eg NAME=value. Returns the name
+// and the value, which is not split into fields.
+func (i *Interpreter) expandAssignment(word token.Token) (string, string, bool) {
+	name, lexeme, _ := strings.Cut(word.Lexeme, "=")
+	_, raw, _ := strings.Cut(word.Raw, "=")
+	if word.Raw == "" {
+		return name, lexeme, true
+	}
+
+	value := &fields{assignment: true}
+	ok := i.expand([]rune(raw), value, false, word.Pos)
+	return name, value.String(), ok
+}
+
+// Expands raw to a single string, without splitting it into fields.
+func (i *Interpreter) expandString(raw []rune, quoted bool, pos token.Position) (string, bool) {
+	value := &fields{}
+	ok := i.expand(raw, value, quoted, pos)
+	return value.String(), ok
+}
+
+// Expands raw to a pattern in which quoted characters only match
+// themselves.
+func (i *Interpreter) expandPattern(raw []rune, quoted bool, pos token.Position) (*pattern.Pattern, bool) {
+	value := &fields{pattern: true}
+	ok := i.expand(raw, value, quoted, pos)
+	return pattern.Compile(value.String()), ok
+}
+
+// Expands the word written as raw, removing its quotes, and writes it to
+// value. inDoubleQuotes is true if the word is between double quotes eg
+// the operand of ${VAR:-word} in "${VAR:-word}". pos is where errors are
+// reported. Returns false if an expansion failed.
+func (i *Interpreter) expand(raw []rune, value *fields, inDoubleQuotes bool, pos token.Position) bool {
+	positional := value.positional // "" gives an empty field, "$@" may give none
+
+	for n := 0; n < len(raw); n++ {
+		c := raw[n]
+		next := rune(0)
+		if n+1 < len(raw) {
+			next = raw[n+1]
+		}
+
+		switch {
+		case c == '\\' && !inDoubleQuotes:
+			n++
+			if n < len(raw) {
+				value.writeQuoted(string(raw[n]))
+			}
+
+		// Only $, `, ", \ and newline are escaped between double quotes
+		case c == '\\':
+			switch next {
+			case '$', '`', '"', '\\':
+				value.writeQuoted(string(next))
+				n++
+			case '\n':
+				n++
+			default:
+				value.writeQuoted(string(c))
+			}
+
+		case c == '\'' && !inDoubleQuotes:
+			end := n + 1
+			for end < len(raw) && raw[end] != '\'' {
+				end++
+			}
+			value.writeQuoted(string(raw[n+1 : min(end, len(raw))]))
+			n = end
+
+		case c == '"':
+			if inDoubleQuotes && value.positional == positional {
+				value.writeQuoted("")
+			}
+			inDoubleQuotes = !inDoubleQuotes
+			positional = value.positional
+
+		// Tilde prefix at the start of a word or after : in assignments
+		case c == '~' && !inDoubleQuotes && (n == 0 || (value.assignment && raw[n-1] == ':')):
+			end := tildeEnd(raw, n, value.assignment)
+			dir, ok := "", false
+			if end > 0 {
+				dir, ok = i.tildeDir(string(raw[n+1 : end]))
+			}
+			if !ok {
+				value.write(string(c))
+				continue
+			}
+			value.writeQuoted(dir)
+			n = end - 1
+
+		case c == '$' && next == '(' && isArithmeticExpansion(raw, n, substitutionEnd(raw, n)):
+			end := substitutionEnd(raw, n)
+			result, ok := i.evaluate(raw[n+3:end-2], pos)
+			if !ok {
+				return false
+			}
+			value.writeSplit(result, inDoubleQuotes)
+			n = end - 1
+
+		case c == '$' && next == '(':
+			end := substitutionEnd(raw, n)
+			output, ok := i.substitute(string(raw[n+2:max(end-1, n+2)]), pos)
+			if !ok {
+				return false
+			}
+			value.writeSplit(output, inDoubleQuotes)
+			n = end - 1
+
+		case c == '`':
+			end := backquoteEnd(raw, n)
+			source := unescapeBackquoted(raw[n+1:max(end-1, n+1)], inDoubleQuotes)
+			output, ok := i.substitute(source, pos)
+			if !ok {
+				return false
+			}
+			value.writeSplit(output, inDoubleQuotes)
+			n = end - 1
+
+		case c == '$' && next == '{':
+			end := braceEnd(raw, n, inDoubleQuotes)
+			if !i.expandParameter(raw[n+2:max(end-1, n+2)], value, inDoubleQuotes, pos) {
+				return false
+			}
+			n = end - 1
+
+		case c == '$' && isSpecialParameter(next):
+			i.writeParameter(string(next), value, inDoubleQuotes)
+			n++
+
+		case c == '$' && isNameStart(next):
+			end := n + 1
+			for end < len(raw) && isNameChar(raw[end]) {
+				end++
+			}
+			i.writeParameter(string(raw[n+1:end]), value, inDoubleQuotes)
+			n = end - 1
+
+		case value.splitText:
+			value.writeSplit(string(c), inDoubleQuotes)
+
+		default:
+			value.writeValue(string(c), inDoubleQuotes)
+		}
+	}
+
+	return true
+}
+
+// Returns the characters splitting fields, " \t\n" if IFS is not set.
+func (i *Interpreter) ifs() string {
+	if ifs, ok := i.env.Get("IFS"); ok {
+		return ifs
+	}
+	return " \t\n"
+}
+
+// Returns the index after the ) closing the $( at start. Nested
+// substitutions and quoted parentheses are skipped over.
+func substitutionEnd(raw []rune, start int) int {
+	depth := 0
+	n := start + 1
+
+	for ; n < len(raw); n++ {
+		switch raw[n] {
+		case '\\':
+			n++
+
+		case '\'', '"':
+			n = quoteEnd(raw, n)
+
+		case '(':
+			depth++
+
+		case ')':
+			depth--
+			if depth == 0 {
+				return n + 1
+			}
+		}
+	}
+
+	return len(raw)
+}
+
+// Returns the index after the } closing the ${ at start. Nested
+// expansions and quoted braces are skipped over. Single quotes are not
+// quotes inside double quotes.
+func braceEnd(raw []rune, start int, inDoubleQuotes bool) int {
+	for n := start + 2; n < len(raw); n++ {
+		switch {
+		case raw[n] == '\\':
+			n++
+
+		case raw[n] == '"' || (raw[n] == '\'' && !inDoubleQuotes):
+			n = quoteEnd(raw, n)
+
+		case raw[n] == '$' && n+1 < len(raw) && raw[n+1] == '(':
+			n = substitutionEnd(raw, n) - 1
+
+		case raw[n] == '$' && n+1 < len(raw) && raw[n+1] == '{':
+			n = braceEnd(raw, n, inDoubleQuotes) - 1
+
+		case raw[n] == '}':
+			return n + 1
+		}
+	}
+
+	return len(raw)
+}
+
+// Returns the index of the quote closing the one at start
+func quoteEnd(raw []rune, start int) int {
+	quote := raw[start]
+
+	n := start + 1
+	for ; n < len(raw) && raw[n] != quote; n++ {
+		if raw[n] == '\\' && quote == '"' {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,166 @@
+package interpreter
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ivf8/simp-shell/pkg/ast"
+)
+
+// Functions calls can be nested this deep, so that endless recursion
+// fails instead of exhausting the stack of the shell.
+const MAX_FUNCTION_DEPTH = 1000
+
+// Defines the function, replacing any other with the same name.
+func (i *Interpreter) VisitFunctionCmd(cmd *ast.FunctionCmd) any {
+	i.functions[cmd.Name.Lexeme] = cmd
+	i.status = 0
+	return nil
+}
+
+// Runs the body of function with args as positional parameters. Variables
+// declared local are kept in a new scope, seen by the functions it calls
+// too. Loops of the caller are not stopped by break and continue in the
+// function. The status is that given to return or of the last command run.
+func (i *Interpreter) call(cmd *ast.PrimaryCmd, function *ast.FunctionCmd, args []string) {
+	if i.functionDepth >= MAX_FUNCTION_DEPTH {
+		i.eieneErrors.InterpreterError(cmd.Pos(), fmt.Sprintf(
+			"%s: maximum function nesting level exceeded (%d)", function.Name.Lexeme, MAX_FUNCTION_DEPTH,
+		))
+		i.status = 1
+		return
+	}
+
+	positional, env, scope, loopDepth := i.positional, i.env, i.functionScope, i.loopDepth
+	defer func() {
+		i.positional, i.env, i.functionScope, i.loopDepth = positional, env, scope, loopDepth
+		i.functionDepth--
+	}()
+
+	i.positional = args
+	i.env = i.env.NewScope()
+	i.functionScope = i.env
+	i.loopDepth = 0
+	i.functionDepth++
+
+	function.Body.Accept(i)
+
+	if i.signal == RETURN_SIGNAL {
+		i.signal = NO_SIGNAL
+	}
+}
+
+// Execute return builtin command. Stops the function being run with the
+// status given, that of the last command by default.
+func (i *Interpreter) functionReturn(cmd *ast.PrimaryCmd, args []string, lastStatus int) {
+	if i.functionDepth == 0 {
+		i.eieneErrors.InterpreterError(cmd.Pos(), "return: can only `return' from a function")
+		i.status = 1
+		return
+	}
+	if len(args) > 1 {
+		i.eieneErrors.InterpreterError(cmd.Pos(), "return: too many arguments")
+		i.status = 1
+		return
+	}
+
+	i.status = lastStatus
+	if len(args) == 1 {
+		status, err := strconv.Atoi(args[0])
+		if err != nil {
+			i.eieneErrors.InterpreterError(cmd.Pos(), "return: "+args[0]+": numeric argument required")
+			status = 2
+		}
+		i.status = status & 0xff
+	}
+
+	i.signal = RETURN_SIGNAL
+}
+
+// Execute local builtin command, and declare for variables. Each argument
+// is a variable declared in the scope of the function being run, set
+// first if given as NAME=value. It hides any variable with the same name
+// until the function returns.
+func (i *Interpreter) local(cmd *ast.PrimaryCmd, builtin string, args []string) {
+	if i.functionScope == nil {
+		i.eieneErrors.InterpreterError(cmd.Pos(), builtin+": can only be used in a function")
+		i.status = 1
+		return
+	}
+
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !isName(name) {
+			i.eieneErrors.InterpreterError(cmd.Pos(), builtin+": `"+arg+"': not a valid identifier")
+			i.status = 1
+			continue
+		}
+
+		if err := i.functionScope.Local(name); err != nil {
+			i.eieneErrors.InterpreterError(cmd.Pos(), name+": "+err.Error())
+			i.status = 1
+			continue
+		}
+		if hasValue {
+			i.functionScope.Set(name, value)
+		}
+	}
+}
+
+// Execute declare builtin command. -f prints the definitions of the
+// functions given, of all of them without arguments, and -F only their
+// names. The status is 1 if one of them is not defined. Without options
+// the variables given are declared like with local, or set if no function
+// is being run, and all variables are listed if none is given.
+func (i *Interpreter) declare(cmd *ast.PrimaryCmd, args []string) {
+	mode := ""
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+
+		for _, flag := range args[0][1:] {
+			if flag != 'f' && flag != 'F' {
+				i.eieneErrors.InterpreterError(cmd.Pos(), "declare: "+args[0]+": invalid option")
+				i.status = 2
+				return
+			}
+			if mode != "F" {
+				mode = string(flag)
+			}
+		}
+		args = args[1:]
+	}
+
+	if mode == "" {
+		if i.functionScope != nil && len(args) > 0 {
+			i.local(cmd, "declare", args)
+		} else {
+			i.declareVariables(cmd, "declare", args, func(string) {}, func(string) bool { return true })
+		}
+		return
+	}
+
+	names := args
+	if len(names) == 0 {
+		names = slices.Sorted(maps.Keys(i.functions))
+	}
+
+	for _, name := range names {
+		function, ok := i.functions[name]
+		if !ok {
+			i.status = 1
+			continue
+		}
+
+		if mode == "F" {
+			fmt.Fprintf(i.Stdout, "declare -f %s\n", name)
+		} else {
+			fmt.Fprintln(i.Stdout, ast.NewAstFormatter([]ast.Cmd{function}).Format())
+		}
+	}
+}
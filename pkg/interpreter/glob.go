@@ -0,0 +1,140 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ivf8/simp-shell/pkg/ast"
+	"github.com/ivf8/simp-shell/pkg/pattern"
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Shell options set with shopt
+var (
+	SHELL_OPTIONS = []string{
+		"dotglob",  // * matches names starting with .
+		"failglob", // A pattern matching no file is an error
+		"globstar", // ** matches any number of directories
+		"nullglob", // A pattern matching no file is removed
+	}
+	SHELL_OPTIONS_MAP = SliceToMap(SHELL_OPTIONS)
+)
+
+// Replaces the fields that are patterns eg *.go with the files they match.
+// Fields with quoted special characters only were escaped while expanding
+// and are unescaped. Returns false if a pattern matched nothing with
+// failglob set.
+func (i *Interpreter) glob(fields []string, pos token.Position) ([]string, bool) {
+	options := pattern.GlobOptions{
+		DotGlob:  i.options["dotglob"],
+		GlobStar: i.options["globstar"],
+		Dir:      i.dir,
+	}
+
+	list := []string{}
+	for _, field := range fields {
+		if !pattern.HasMeta(field) {
+			list = append(list, pattern.Unescape(field))
+			continue
+		}
+
+		matches := pattern.Glob(field, options)
+		switch {
+		case len(matches) > 0:
+			list = append(list, matches...)
+
+		case i.options["failglob"]:
+			i.eieneErrors.InterpreterError(pos, "no match: "+pattern.Unescape(field))
+			return nil, false
+
+		case !i.options["nullglob"]:
+			list = append(list, pattern.Unescape(field))
+		}
+	}
+
+	return list, true
+}
+
+// Execute shopt builtin command. -s sets the options given and -u unsets
+// them. Otherwise their state is listed, that of all options without
+// arguments, with -p in a form that can be run again and with -q not at
+// all. The status is then 1 if one of the options given is not set.
+func (i *Interpreter) shopt(cmd *ast.PrimaryCmd, args []string) {
+	mode := ""
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+
+		for _, flag := range args[0][1:] {
+			if !strings.ContainsRune("supq", flag) {
+				i.eieneErrors.InterpreterError(cmd.Pos(), "shopt: "+args[0]+": invalid option")
+				i.status = 2
+				return
+			}
+			if flag == 's' || flag == 'u' {
+				if mode != "" && mode[0] != byte(flag) {
+					i.eieneErrors.InterpreterError(cmd.Pos(), "shopt: cannot set and unset shell options simultaneously")
+					i.status = 1
+					return
+				}
+			}
+			mode += string(flag)
+		}
+		args = args[1:]
+	}
+
+	for _, name := range args {
+		if !SHELL_OPTIONS_MAP[name] {
+			i.eieneErrors.InterpreterError(cmd.Pos(), "shopt: "+name+": invalid shell option name")
+			i.status = 1
+			return
+		}
+	}
+
+	set := strings.Contains(mode, "s")
+	if set || strings.Contains(mode, "u") {
+		for _, name := range args {
+			i.options[name] = set
+		}
+
+		// Without names the options set or unset are listed
+		if len(args) > 0 {
+			return
+		}
+		for _, name := range SHELL_OPTIONS {
+			if i.options[name] == set {
+				i.printOption(name, strings.Contains(mode, "p"))
+			}
+		}
+		return
+	}
+
+	names := args
+	if len(names) == 0 {
+		names = SHELL_OPTIONS
+	}
+	for _, name := range names {
+		if len(args) > 0 && !i.options[name] {
+			i.status = 1
+		}
+		if !strings.Contains(mode, "q") {
+			i.printOption(name, strings.Contains(mode, "p"))
+		}
+	}
+}
+
+// Prints whether the option name is set, as a shopt command if reusable
+func (i *Interpreter) printOption(name string, reusable bool) {
+	switch {
+	case reusable && i.options[name]:
+		fmt.Fprintf(i.Stdout, "shopt -s %s\n", name)
+	case reusable:
+		fmt.Fprintf(i.Stdout, "shopt -u %s\n", name)
+	case i.options[name]:
+		fmt.Fprintf(i.Stdout, "%-15s\ton\n", name)
+	default:
+		fmt.Fprintf(i.Stdout, "%-15s\toff\n", name)
+	}
+}
@@ -1,8 +1,18 @@
 package interpreter
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 
 	"github.com/ivf8/simp-shell/pkg/ast"
 	"github.com/ivf8/simp-shell/pkg/eiene_errors"
@@ -11,33 +21,111 @@ import (
 
 // Built in commands
 var (
-	BUILTINS     = []string{"exit", "cd"}
+	BUILTINS = []string{
+		"exit", "cd", "jobs", "fg", "bg", "disown", "shift", "set",
+		"export", "unset", "readonly", "shopt", "break", "continue",
+		"return", "local", "declare",
+	}
 	BUILTINS_MAP = SliceToMap(BUILTINS)
 )
 
+// Builtins whose arguments in assignment form eg NAME=$v are expanded as
+// assignment words
+var (
+	DECLARATION_BUILTINS     = []string{"export", "readonly", "local", "declare"}
+	DECLARATION_BUILTINS_MAP = SliceToMap(DECLARATION_BUILTINS)
+)
+
 type Interpreter struct {
-	cmds        []ast.Cmd
 	eieneErrors *eiene_errors.EieneErrors
+
+	status  int  // Exit status of the last command run, $?
+	exiting bool // exit was run, no more commands are run
+
+	signal       controlSignal // Set by break and continue to stop the commands of loops
+	signalLevels int           // Number of loops the signal applies to
+	loopDepth    int           // Number of loops the command run is in
+	interrupted  *atomic.Bool  // Ctrl-C was pressed while running the command
+
+	env     *Environment    // Shell variables
+	options map[string]bool // Shell options set with shopt eg nullglob
+	dir     string          // Working directory, set by cd
+
+	functions     map[string]*ast.FunctionCmd // Functions defined, by name
+	functionDepth int                         // Number of functions being run
+	functionScope *Environment                // Scope of the local variables of the function run
+
+	substitutionStatus int // Exit status of the last command substitution, -1 if none
+
+	scriptName string   // $0
+	positional []string // Positional parameters $1, $2...
+
+	jobs              *JobTable // Jobs run in the background or stopped
+	job               *Job      // Job the commands run belong to
+	lastBackgroundPid int       // $!
+	terminal          *terminal // Terminal of the shell, nil without job control
+	piped             bool      // Output goes to the next command of a pipeline
+	exitWarned        bool      // Last command was an exit refused for stopped jobs
+
+	// Commands are typed at a prompt. Otherwise errors such as that of
+	// ${A:?} exit the shell.
+	Interactive bool
+
+	// Standard streams of the commands run
+	Stdin  *os.File
+	Stdout *os.File
+	Stderr *os.File
 }
 
-func NewInterpreter(cmds []ast.Cmd, e *eiene_errors.EieneErrors) *Interpreter {
+func NewInterpreter(e *eiene_errors.EieneErrors) *Interpreter {
+	dir, _ := os.Getwd()
+
 	return &Interpreter{
-		cmds:        cmds,
 		eieneErrors: e,
+
+		status:  0,
+		exiting: false,
+
+		signal:       NO_SIGNAL,
+		signalLevels: 0,
+		loopDepth:    0,
+		interrupted:  &atomic.Bool{},
+
+		env:     NewEnvironment(os.Environ()),
+		options: map[string]bool{},
+		dir:     dir,
+
+		functions:     map[string]*ast.FunctionCmd{},
+		functionDepth: 0,
+		functionScope: nil,
+
+		substitutionStatus: -1,
+
+		scriptName: "eiene",
+		positional: []string{},
+
+		jobs:              NewJobTable(),
+		job:               nil,
+		lastBackgroundPid: 0,
+		terminal:          nil,
+		piped:             false,
+		exitWarned:        false,
+
+		Interactive: false,
+
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
 	}
 }
 
-func (i *Interpreter) Interpret() {
-	for _, cmd := range i.cmds {
-		cmd.Accept(i)
+// Runs cmds one after the other. State such as background jobs is kept
+// between calls.
+func (i *Interpreter) Interpret(cmds []ast.Cmd) {
+	for _, cmd := range cmds {
+		i.runForeground(cmd)
 
-		if c, ok := cmd.(*ast.LogicalCmd); ok {
-			if c.Operator.Type == token.OR && !i.eieneErrors.HadError {
-				i.eieneErrors.HadInterpreterError = false
-			}
-		}
-
-		if i.eieneErrors.HadExitError {
+		if i.exiting {
 			break
 		}
 
@@ -45,96 +133,413 @@ func (i *Interpreter) Interpret() {
 	}
 }
 
+// Sets $0 to name and the positional parameters to args
+func (i *Interpreter) SetPositional(name string, args []string) {
+	i.scriptName = name
+	i.positional = args
+}
+
+// Returns the exit status of the last command run
+func (i *Interpreter) Status() int {
+	return i.status
+}
+
+// Runs the right command after && if the left one succeeded, or after ||
+// if it failed.
 func (i *Interpreter) VisitLogicalCmd(cmd *ast.LogicalCmd) any {
-	switch cmd.Operator.Type {
-	case token.AND:
-		cmd.Left.Accept(i)
-		if i.eieneErrors.HadError {
-			return nil
-		}
+	cmd.Left.Accept(i)
+	if i.stopped() {
+		return nil
+	}
 
+	succeeded := i.status == 0
+	if succeeded == (cmd.Operator.Type == token.AND) {
 		cmd.Right.Accept(i)
-		break
+	}
 
-	case token.OR:
-		cmd.Left.Accept(i)
-		// Return if no error was encountered except if the error is
-		// ExitError which is raised by the exit command
-		if !i.eieneErrors.HadError || i.eieneErrors.HadExitError {
-			return nil
+	return nil
+}
+
+// Runs all commands of the pipeline at once, each one reading the output
+// of the previous one. Errors of the pipeline are those of its last command.
+func (i *Interpreter) VisitPipelineCmd(cmd *ast.PipelineCmd) any {
+	stages := []*Interpreter{}
+	wg := sync.WaitGroup{}
+
+	stdin := i.Stdin
+	for n, stageCmd := range cmd.Cmds {
+		stage := i.subshell()
+		stage.Stdin = stdin
+		stage.piped = n < len(cmd.Cmds)-1
+
+		if n < len(cmd.Cmds)-1 {
+			reader, writer, err := os.Pipe()
+			if err != nil {
+				i.eieneErrors.InterpreterError(stageCmd.Pos(), err.Error())
+				if stdin != i.Stdin {
+					stdin.Close()
+				}
+				break
+			}
+
+			stage.Stdout = writer
+			stdin = reader
 		}
 
-		i.eieneErrors.ResetErrors()
-		cmd.Right.Accept(i)
+		stages = append(stages, stage)
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			stageCmd.Accept(stage)
+
+			// Let the previous command know nobody is reading and the next
+			// one that nothing more will be written
+			if stage.Stdin != i.Stdin {
+				stage.Stdin.Close()
+			}
+			if stage.Stdout != i.Stdout {
+				stage.Stdout.Close()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(stages) != len(cmd.Cmds) {
+		i.status = 1
+	} else {
+		i.status = stages[len(stages)-1].status
+
+		last := stages[len(stages)-1].eieneErrors
+		if last.HadInterpreterError {
+			i.eieneErrors.HadInterpreterError = true
+			i.eieneErrors.HadError = true
+			i.eieneErrors.Errors = append(i.eieneErrors.Errors, last.Errors...)
+		}
 	}
 
 	return nil
 }
 
 func (i *Interpreter) VisitPrimaryCmd(cmd *ast.PrimaryCmd) any {
-	var args []string
-	for _, arg := range cmd.Arguments {
-		args = append(args, arg.Lexeme)
+	i.substitutionStatus = -1
+	words, ok := i.expandCommand(cmd)
+	if !ok {
+		i.status = 1
+		return nil
+	}
+
+	restore, ok := i.redirect(cmd.Redirections)
+	defer restore()
+
+	if !ok {
+		i.status = 1
+		return nil
+	}
+
+	exitWarned := i.exitWarned
+	i.exitWarned = false
+
+	// Without a program the assignments set shell variables
+	if cmd.ProgramName.Type == "" || len(words) == 0 {
+		failed := false
+		for _, assignment := range cmd.Assignments {
+			name, value, ok := i.expandAssignment(assignment)
+			if !ok {
+				failed = true
+				break
+			}
+			if err := i.env.Set(name, value); err != nil {
+				i.eieneErrors.InterpreterError(assignment.Pos, name+": "+err.Error())
+				failed = true
+			}
+		}
+
+		// The status is that of the last command substitution if any
+		i.status = max(i.substitutionStatus, 0)
+		if failed {
+			i.status = 1
+		}
+		return nil
 	}
 
-	if BUILTINS_MAP[cmd.ProgramName.Lexeme] {
-		switch cmd.ProgramName.Lexeme {
+	// Otherwise they only apply to the command run
+	if len(cmd.Assignments) > 0 {
+		env := i.env
+		defer func() { i.env = env }()
+
+		if !i.assignTemporarily(cmd.Assignments) {
+			i.status = 1
+			return nil
+		}
+	}
+
+	lastStatus := i.status
+	i.status = 0
+	name, args := words[0], words[1:]
+
+	// Functions are found before builtins and programs
+	if function, ok := i.functions[name]; ok {
+		i.status = lastStatus
+		i.call(cmd, function, args)
+		return nil
+	}
+
+	if BUILTINS_MAP[name] {
+		switch name {
 		case "exit":
-			i.exit()
+			i.status = lastStatus
+			i.exit(cmd, args, exitWarned)
 
 		case "cd":
 			if len(args) == 0 {
 				args = append(args, "~")
 			}
-			i.cd(args[0])
+			i.cd(cmd, args[0])
+
+		case "jobs":
+			i.listJobs()
+
+		case "fg":
+			i.fg(cmd, args)
+
+		case "bg":
+			i.bg(cmd, args)
+
+		case "disown":
+			i.disown(cmd, args)
+
+		case "shift":
+			i.shift(cmd, args)
+
+		case "set":
+			i.set(cmd, args)
+
+		case "export":
+			i.export(cmd, args)
+
+		case "unset":
+			i.unset(cmd, args)
+
+		case "readonly":
+			i.readonly(cmd, args)
+
+		case "shopt":
+			i.shopt(cmd, args)
+
+		case "break":
+			i.loopControl(cmd, BREAK_SIGNAL, args)
+
+		case "continue":
+			i.loopControl(cmd, CONTINUE_SIGNAL, args)
+
+		case "return":
+			i.functionReturn(cmd, args, lastStatus)
+
+		case "local":
+			i.local(cmd, "local", args)
+
+		case "declare":
+			i.declare(cmd, args)
 		}
 
 		return nil
 	}
 
-	_cmd := exec.Command(cmd.ProgramName.Lexeme, args...)
-	_cmd.Stdin = os.Stdin
-	_cmd.Stdout = os.Stdout
-	_cmd.Stderr = os.Stderr
+	path, err := i.lookPath(name)
+	if err != nil {
+		i.eieneErrors.InterpreterError(cmd.Pos(), err.Error())
+		i.status = 127
+		return nil
+	}
 
-	err := _cmd.Run()
+	_cmd := exec.Command(path, args...)
+	_cmd.Args[0] = name
+	_cmd.Env = i.env.Environ()
+	_cmd.Dir = i.dir
+	_cmd.Stdin = i.Stdin
+	_cmd.Stdout = i.Stdout
+	_cmd.Stderr = i.Stderr
+
+	process, err := i.job.start(_cmd, i.terminal, !i.piped)
+	if err != nil {
+		i.eieneErrors.InterpreterError(cmd.Pos(), err.Error())
+
+		i.status = 126
+		if errors.Is(err, exec.ErrNotFound) {
+			i.status = 127
+		}
+		return nil
+	}
 
+	i.status, err = i.job.wait(process)
 	if err != nil {
-		i.eieneErrors.InterpreterError(err.Error())
+		i.eieneErrors.InterpreterError(cmd.Pos(), err.Error())
+	}
+
+	// Ctrl-C also stops the loops running the command
+	if i.status == INTERRUPTED_STATUS {
+		i.interrupted.Store(true)
 	}
 
 	return nil
 }
 
-// Execute exit builtin command
-func (i *Interpreter) exit() {
+// Returns a copy of the interpreter for running a command alongside the
+// current one eg a stage of a pipeline. Changes to its variables or working
+// directory do not affect the current one.
+func (i *Interpreter) subshell() *Interpreter {
+	child := *i
+	child.eieneErrors = i.eieneErrors.Child()
+	child.env = i.env.Copy()
+	child.options = maps.Clone(i.options)
+	child.functions = maps.Clone(i.functions)
+
+	// The local variables of the function run are those of the copy
+	if i.functionScope != nil {
+		scope, copied := i.env, child.env
+		for scope != i.functionScope {
+			scope, copied = scope.parent, copied.parent
+		}
+		child.functionScope = copied
+	}
+
+	return &child
+}
+
+// Execute exit builtin command. The shell exits with the status given or
+// that of the last command. With stopped jobs, exit has to be run twice in
+// a row and the jobs are then sent SIGHUP.
+func (i *Interpreter) exit(cmd *ast.PrimaryCmd, args []string, warned bool) {
+	if len(args) > 1 {
+		i.eieneErrors.InterpreterError(cmd.Pos(), "exit: too many arguments")
+		i.status = 1
+		return
+	}
+
+	if len(args) == 1 {
+		status, err := strconv.Atoi(args[0])
+		if err != nil {
+			i.eieneErrors.InterpreterError(cmd.Pos(), "exit: "+args[0]+": numeric argument required")
+			status = 2
+		}
+		i.status = status & 0xff
+	}
+
+	stopped := i.jobs.stopped()
+	if len(stopped) > 0 && !warned {
+		fmt.Fprintln(i.Stderr, "There are stopped jobs.")
+		i.exitWarned = true
+		i.status = 1
+		return
+	}
+
+	if i.terminal != nil {
+		for _, job := range stopped {
+			if pgid := job.processGroup(); pgid != 0 {
+				i.terminal.hangUpGroup(pgid)
+			}
+		}
+	}
+
+	i.exiting = true
 	i.eieneErrors.ExitError()
 }
 
-// Execute cd builtin command
-func (i *Interpreter) cd(dir string) {
+// Execute shift builtin command. Removes the first n positional
+// parameters, 1 by default.
+func (i *Interpreter) shift(cmd *ast.PrimaryCmd, args []string) {
+	if len(args) > 1 {
+		i.eieneErrors.InterpreterError(cmd.Pos(), "shift: too many arguments")
+		i.status = 1
+		return
+	}
+
+	n := 1
+	if len(args) == 1 {
+		var err error
+		n, err = strconv.Atoi(args[0])
+		if err != nil {
+			i.eieneErrors.InterpreterError(cmd.Pos(), "shift: "+args[0]+": numeric argument required")
+			i.status = 1
+			return
+		}
+	}
+
+	if n < 0 || n > len(i.positional) {
+		i.eieneErrors.InterpreterError(cmd.Pos(), "shift: "+strconv.Itoa(n)+": shift count out of range")
+		i.status = 1
+		return
+	}
+
+	i.positional = i.positional[n:]
+}
+
+// Execute set builtin command. Only set -- args replacing the positional
+// parameters is supported.
+func (i *Interpreter) set(cmd *ast.PrimaryCmd, args []string) {
+	if len(args) == 0 {
+		return
+	}
+
+	if args[0] == "--" {
+		args = args[1:]
+	} else if strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[0], "+") {
+		i.eieneErrors.InterpreterError(cmd.Pos(), "set: "+args[0]+": invalid option")
+		i.status = 2
+		return
+	}
+
+	i.positional = append([]string{}, args...)
+}
+
+// Execute cd builtin command. Only the working directory of the
+// interpreter changes, not that of the shell process, so that subshells
+// running alongside keep theirs.
+func (i *Interpreter) cd(cmd *ast.PrimaryCmd, dir string) {
 	_dir := dir
 	switch dir {
 	case "-":
-		oldPwd, ok := os.LookupEnv("OLDPWD")
+		oldPwd, ok := i.env.Get("OLDPWD")
 		if !ok {
 			_dir = "."
 		} else {
 			_dir = oldPwd
 		}
 	case "~":
-		homeDir, _ := os.UserHomeDir()
-		_dir = homeDir
+		_dir, _ = i.tildeDir("")
 	}
 
-	prevDir, _ := os.LookupEnv("PWD")
-	if err := os.Chdir(_dir); err != nil {
-		i.eieneErrors.InterpreterError(err.Error())
-	} else {
-		pwd, _ := os.Getwd()
-		os.Setenv("PWD", pwd)
-		os.Setenv("OLDPWD", prevDir)
+	target := i.absPath(_dir)
+	info, err := os.Stat(target)
+	if err == nil && !info.IsDir() {
+		err = syscall.ENOTDIR
+	}
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+
+		i.eieneErrors.InterpreterError(cmd.Pos(), (&fs.PathError{Op: "chdir", Path: _dir, Err: err}).Error())
+		i.status = 1
+		return
+	}
+
+	prevDir, _ := i.env.Get("PWD")
+	i.dir = target
+	i.env.Set("PWD", target)
+	i.env.Set("OLDPWD", prevDir)
+}
+
+// Returns path relative to the working directory of the interpreter as an
+// absolute path
+func (i *Interpreter) absPath(path string) string {
+	if filepath.IsAbs(path) || i.dir == "" {
+		return path
 	}
+	return filepath.Join(i.dir, path)
 }
 
 // Creates a map from a slice
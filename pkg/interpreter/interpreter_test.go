@@ -1,11 +1,21 @@
 package interpreter_test
 
 import (
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ivf8/simp-shell/pkg/ast"
 	"github.com/ivf8/simp-shell/pkg/eiene_errors"
 	"github.com/ivf8/simp-shell/pkg/interpreter"
+	"github.com/ivf8/simp-shell/pkg/parser"
+	"github.com/ivf8/simp-shell/pkg/scanner"
 	"github.com/ivf8/simp-shell/pkg/token"
 )
 
@@ -56,8 +66,8 @@ func interpreterHelper(cmds []ast.Cmd) {
 	EieneErrors.ResetErrors()
 	EieneErrors.HadInterpreterError = false
 
-	_interpreter := interpreter.NewInterpreter(cmds, EieneErrors)
-	_interpreter.Interpret()
+	_interpreter := interpreter.NewInterpreter(EieneErrors)
+	_interpreter.Interpret(cmds)
 }
 
 func TestInterpreter(t *testing.T) {
@@ -88,6 +98,275 @@ func TestInterpreter(t *testing.T) {
 	}
 }
 
+func TestPipeline(t *testing.T) {
+	argTokens := func(args ...string) []token.Token {
+		tokens := []token.Token{}
+		for _, arg := range args {
+			tokens = append(tokens, newToken(token.ARG, arg))
+		}
+		return tokens
+	}
+
+	echo := ast.NewPrimaryCmd(newToken(token.PROG_NAME, "echo"), argTokens("hello", "world"))
+	tr := ast.NewPrimaryCmd(newToken(token.PROG_NAME, "tr"), argTokens("a-z", "A-Z"))
+	rev := ast.NewPrimaryCmd(newToken(token.PROG_NAME, "rev"), argTokens())
+
+	tests := []struct {
+		cmd                      ast.Cmd
+		expectedOutput           string
+		expectedInterpreterError bool
+	}{
+		{ast.NewPipelineCmd([]ast.Cmd{echo, tr}), "HELLO WORLD\n", false},
+		{ast.NewPipelineCmd([]ast.Cmd{echo, tr, rev}), "DLROW OLLEH\n", false},
+		{ast.NewPipelineCmd([]ast.Cmd{INVALID_CMD, tr}), "", false},
+		{ast.NewPipelineCmd([]ast.Cmd{echo, INVALID_CMD}), "", true},
+		{ast.NewPipelineCmd([]ast.Cmd{echo, EXIT_CMD}), "", false},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_interpreter.Stdout = writer
+
+		_interpreter.Interpret([]ast.Cmd{test.cmd})
+		writer.Close()
+
+		output, _ := io.ReadAll(reader)
+		reader.Close()
+
+		if string(output) != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q",
+				ast.NewAstPrinter([]ast.Cmd{test.cmd}).SPrint(), output, test.expectedOutput)
+		}
+		if eieneErrors.HadInterpreterError != test.expectedInterpreterError {
+			t.Errorf("Error interpreting (%s) Got %v. Expected %v",
+				ast.NewAstPrinter([]ast.Cmd{test.cmd}).SPrint(),
+				eieneErrors.HadInterpreterError, test.expectedInterpreterError)
+		}
+		if eieneErrors.HadExitError {
+			t.Errorf("exit in a pipeline exited the shell")
+		}
+	}
+}
+
+func TestPipelineStagesDoNotChangeTheShell(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cd := func(dir string) ast.Cmd {
+		return ast.NewPrimaryCmd(CD, []token.Token{newToken(token.ARG, dir)})
+	}
+	echo := ast.NewPrimaryCmd(newToken(token.PROG_NAME, "echo"), []token.Token{})
+	pwd := ast.NewPrimaryCmd(newToken(token.PROG_NAME, "pwd"), []token.Token{})
+
+	cmds := []ast.Cmd{
+		cd("/"),
+		ast.NewPipelineCmd([]ast.Cmd{cd("/usr"), echo}),
+		ast.NewPipelineCmd([]ast.Cmd{echo, cd("/usr")}),
+		pwd,
+	}
+
+	eieneErrors := eiene_errors.NewEieneErrors(false)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_interpreter.Stdout = writer
+
+	_interpreter.Interpret(cmds)
+	writer.Close()
+
+	output, _ := io.ReadAll(reader)
+	reader.Close()
+
+	if string(output) != "\n/\n" {
+		t.Errorf("cd in a pipeline changed the directory of the shell. pwd output %q", output)
+	}
+	if dir, _ := os.Getwd(); dir != wd {
+		t.Errorf("cd in a pipeline changed the directory of the process to %s", dir)
+		os.Chdir(wd)
+	}
+}
+
+// Scans, parses and interprets source with new errors.
+func runHelper(source string) (*interpreter.Interpreter, *eiene_errors.EieneErrors) {
+	eieneErrors := eiene_errors.NewEieneErrors(false)
+
+	tokens := scanner.NewScanner(source, eieneErrors, nil).ScanTokens()
+	cmds := parser.NewParser(tokens).Parse()
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
+	_interpreter.Interpret(cmds)
+
+	return _interpreter, eieneErrors
+}
+
+// Runs source with _interpreter and returns what it wrote to stdout
+func outputHelper(t *testing.T, _interpreter *interpreter.Interpreter, eieneErrors *eiene_errors.EieneErrors, source string) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_interpreter.Stdout = writer
+
+	tokens := scanner.NewScanner(source, eieneErrors, nil).ScanTokens()
+	_interpreter.Interpret(parser.NewParser(tokens).Parse())
+	writer.Close()
+
+	output, _ := io.ReadAll(reader)
+	reader.Close()
+
+	return string(output)
+}
+
+func TestRedirections(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	tests := []struct {
+		cmd             string
+		file            string
+		expectedContent string
+		expectedStatus  int
+	}{
+		{"echo hello > " + file("out"), "out", "hello\n", 0},
+		{"echo world >>" + file("out"), "out", "hello\nworld\n", 0},
+		{"cat <" + file("out") + " 1>" + file("copy"), "copy", "hello\nworld\n", 0},
+		{"echo hi>" + file("out"), "out", "hi\n", 0},
+		{"> " + file("empty"), "empty", "", 0},
+		{"cat " + file("missing") + " 2>" + file("err"), "err",
+			"cat: " + file("missing") + ": No such file or directory\n", 1},
+		{"cat " + file("missing") + " " + file("out") + " >" + file("both") + " 2>&1", "both",
+			"cat: " + file("missing") + ": No such file or directory\nhi\n", 1},
+		{"cat " + file("missing") + " " + file("out") + " &>" + file("all"), "all",
+			"cat: " + file("missing") + ": No such file or directory\nhi\n", 1},
+		{"cat " + file("out") + " 2>&1 >" + file("stdout"), "stdout", "hi\n", 0},
+		{"cat <>" + file("rw"), "rw", "", 0},
+		{"cat < " + file("missing"), "missing", "", 1},
+		{"ls > " + file("missing") + "/out", "missing", "", 1},
+		{"ls 3> " + file("fd"), "fd", "", 1},
+		{"cd " + dir + "; echo hi >&- 2>&-", "-", "", 1},
+		{"cd " + dir + "; cat <&- 2>&-", "-", "", 1},
+	}
+
+	for _, test := range tests {
+		_interpreter, _ := runHelper(test.cmd)
+
+		content, _ := os.ReadFile(file(test.file))
+		if string(content) != test.expectedContent {
+			t.Errorf("Interpreting (%s) wrote %q. Expected %q", test.cmd, content, test.expectedContent)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestBuiltinRedirections(t *testing.T) {
+	errFile := filepath.Join(t.TempDir(), "err")
+
+	eieneErrors := eiene_errors.NewEieneErrors(true)
+	tokens := scanner.NewScanner("cd unknown-directory-001 2>"+errFile, eieneErrors, nil).ScanTokens()
+	interpreter.NewInterpreter(eieneErrors).Interpret(parser.NewParser(tokens).Parse())
+
+	content, _ := os.ReadFile(errFile)
+	if !strings.Contains(string(content), "unknown-directory-001") {
+		t.Errorf("cd error was not redirected. Got %q", content)
+	}
+	if !eieneErrors.HadInterpreterError {
+		t.Errorf("cd to an unknown directory was expected to fail")
+	}
+}
+
+func TestBackgroundJobs(t *testing.T) {
+	stderrFile := filepath.Join(t.TempDir(), "stderr")
+	stderr, err := os.Create(stderrFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+
+	eieneErrors := eiene_errors.NewEieneErrors(false)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
+	_interpreter.Stderr = stderr
+
+	interpret := func(source string) {
+		tokens := scanner.NewScanner(source, eieneErrors, nil).ScanTokens()
+		_interpreter.Interpret(parser.NewParser(tokens).Parse())
+	}
+
+	start := time.Now()
+	interpret("sleep 0.2 & sh -c 'sleep 0.3; exit 3' &")
+	if time.Since(start) >= 200*time.Millisecond {
+		t.Errorf("Background jobs were waited for")
+	}
+
+	// Neither job is done yet, then both are and are reported in order
+	_interpreter.ReportDoneJobs()
+	time.Sleep(500 * time.Millisecond)
+	_interpreter.ReportDoneJobs()
+	_interpreter.ReportDoneJobs()
+
+	content, _ := os.ReadFile(stderrFile)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+
+	expected := []*regexp.Regexp{
+		regexp.MustCompile(`^\[1\] [1-9][0-9]*$`),
+		regexp.MustCompile(`^\[2\] [1-9][0-9]*$`),
+		regexp.MustCompile(`^\[1\]-  Done {20}sleep 0\.2$`),
+		regexp.MustCompile(`^\[2\]\+  Exit 3 {18}sh -c 'sleep 0\.3; exit 3'$`),
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("Background jobs printed %q", content)
+	}
+	for n, line := range lines {
+		if !expected[n].MatchString(line) {
+			t.Errorf("Background jobs printed %q. Expected to match %s", line, expected[n])
+		}
+	}
+}
+
+func TestBuiltinBackgroundJobs(t *testing.T) {
+	stderrFile := filepath.Join(t.TempDir(), "stderr")
+	stderr, err := os.Create(stderrFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+
+	eieneErrors := eiene_errors.NewEieneErrors(false)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
+	_interpreter.Stderr = stderr
+
+	start := time.Now()
+	output := outputHelper(t, _interpreter, eieneErrors,
+		"cd /; cd /usr & A=1 & f() { sleep 0.3; }; f > /dev/null & echo \"[$!]\" $PWD \"[$A]\"; pwd")
+
+	if time.Since(start) >= 300*time.Millisecond {
+		t.Errorf("A background function was waited for")
+	}
+	if expected := "[] / []\n/\n"; output != expected {
+		t.Errorf("Builtins run in the background changed the shell. Got %q. Expected %q", output, expected)
+	}
+
+	content, _ := os.ReadFile(stderrFile)
+	if expected := "[1]\n[2]\n[3]\n"; string(content) != expected {
+		t.Errorf("Builtins run in the background printed %q. Expected %q", content, expected)
+	}
+}
+
 func TestExitBuiltinCommand(t *testing.T) {
 	tests := []struct {
 		cmds              []ast.Cmd
@@ -144,3 +423,766 @@ func TestCdBuiltinCommand(t *testing.T) {
 		}
 	}
 }
+
+func TestJobBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _ := os.Create(filepath.Join(dir, "stdout"))
+	stderr, _ := os.Create(filepath.Join(dir, "stderr"))
+	defer stdout.Close()
+	defer stderr.Close()
+
+	eieneErrors := eiene_errors.NewEieneErrors(false)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
+	_interpreter.Stdout = stdout
+	_interpreter.Stderr = stderr
+
+	interpret := func(source string) {
+		eieneErrors.ResetErrors()
+		eieneErrors.HadInterpreterError = false
+
+		tokens := scanner.NewScanner(source, eieneErrors, nil).ScanTokens()
+		_interpreter.Interpret(parser.NewParser(tokens).Parse())
+	}
+
+	// Kill the jobs left once done
+	defer func() {
+		content, _ := os.ReadFile(filepath.Join(dir, "stderr"))
+		for _, match := range regexp.MustCompile(`(?m)^\[\d+\] (\d+)$`).FindAllStringSubmatch(string(content), -1) {
+			pid, _ := strconv.Atoi(match[1])
+			if process, err := os.FindProcess(pid); err == nil {
+				process.Kill()
+			}
+		}
+	}()
+
+	interpret("sh -c 'kill -STOP $$' &")
+	time.Sleep(200 * time.Millisecond)
+	interpret("sleep 5 & jobs")
+	interpret("disown %2; jobs")
+
+	interpret("exit")
+	if eieneErrors.HadExitError {
+		t.Errorf("exit was expected to be refused with stopped jobs")
+	}
+
+	interpret("fg")
+	if !eieneErrors.HadInterpreterError {
+		t.Errorf("fg was expected to fail without job control")
+	}
+
+	interpret("exit; exit")
+	if !eieneErrors.HadExitError {
+		t.Errorf("exit run twice was expected to exit")
+	}
+
+	output, _ := os.ReadFile(filepath.Join(dir, "stdout"))
+	expected := "[1]+  Stopped                 sh -c 'kill -STOP $$'\n" +
+		"[2]-  Running                 sleep 5 &\n" +
+		"[1]+  Stopped                 sh -c 'kill -STOP $$'\n"
+	if string(output) != expected {
+		t.Errorf("jobs printed %q. Expected %q", output, expected)
+	}
+
+	errors, _ := os.ReadFile(filepath.Join(dir, "stderr"))
+	if strings.Count(string(errors), "There are stopped jobs.\n") != 2 {
+		t.Errorf("exit printed %q. Expected two warnings", errors)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+	}{
+		{"sh -c 'exit 3'; echo $?", "3\n"},
+		{"false || echo $?", "1\n"},
+		{"true && echo $?", "0\n"},
+		{"false && echo no; echo $?", "1\n"},
+		{"true || echo no; echo $?", "0\n"},
+		{"sh -c 'kill -TERM $$'; echo $?", "143\n"},
+		{"invalid-prog-001 2>/dev/null; echo $?", "127\n"},
+		{"cd unknown-directory-001 2>/dev/null || echo $?", "1\n"},
+		{"false | true; echo $?", "0\n"},
+		{"true | false; echo $?", "1\n"},
+		{"false; echo '$?' \"$?\" \\$?", "$? 1 $?\n"},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+	}
+}
+
+func TestExitBuiltinStatus(t *testing.T) {
+	tests := []struct {
+		cmd               string
+		expectedStatus    int
+		expectedExitError bool
+	}{
+		{"exit 3", 3, true},
+		{"false; exit", 1, true},
+		{"exit 0; false", 0, true},
+		{"exit -1", 255, true},
+		{"exit 256", 0, true},
+		{"exit abc", 2, true},
+		{"exit 1 2", 1, false},
+		{"exit 4 | true", 0, false},
+		{"false || exit 5 && exit 6", 5, true},
+	}
+
+	for _, test := range tests {
+		_interpreter, eieneErrors := runHelper(test.cmd)
+
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+		if eieneErrors.HadExitError != test.expectedExitError {
+			t.Errorf("Interpreting (%s) exit error got %v. Expected %v",
+				test.cmd, eieneErrors.HadExitError, test.expectedExitError)
+		}
+	}
+}
+
+func TestPositionalParameters(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"echo $0 $# $1 $2 $3", "script 3 a b c\n", 0},
+		{"printf '<%s>' \"$@\"", "<a><b c><>", 0},
+		{"printf '<%s>' \"$*\"", "<a b c >", 0},
+		{"printf '<%s>' $@", "<a><b><c>", 0},
+		{"printf '<%s>' \"x$@y\"", "<xa><b c><y>", 0},
+		{"shift; echo $# \"$1\"", "2 b c\n", 0},
+		{"shift 3; echo $#", "0\n", 0},
+		{"shift 4", "", 1},
+		{"shift 4 || echo $#", "3\n", 0},
+		{"shift x", "", 1},
+		{"set -- p q; echo $# $1 $2", "2 p q\n", 0},
+		{"set --; echo \"$@\" $#", "0\n", 0},
+		{"set --; printf '<%s>' \"\"", "<>", 0},
+		{"set -x", "", 2},
+		{"echo '$1' \\$1 \"$1\"", "$1 $1 a\n", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+		_interpreter.SetPositional("script", []string{"a", "b c", ""})
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestVariableBuiltins(t *testing.T) {
+	t.Setenv("EIENE_TEST_HOME", "/home/eiene")
+
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"echo \"$EIENE_TEST_HOME\" $EIENE_TEST_HOME/bin x$EIENE_NONE.y", "/home/eiene /home/eiene/bin x.y\n", 0},
+		{"export EIENE_A=1; sh -c 'echo $EIENE_A'", "1\n", 0},
+		{"export EIENE_A=1; unset EIENE_A; sh -c 'echo x$EIENE_A'", "x\n", 0},
+		{"export EIENE_A; sh -c 'echo ${EIENE_A-unset}'", "unset\n", 0},
+		{"export EIENE_A=\"it's\"; export -p | grep EIENE_A", "export EIENE_A='it'\\''s'\n", 0},
+		{"readonly EIENE_A=1; export EIENE_A=2", "", 1},
+		{"readonly EIENE_A=1; unset EIENE_A || echo $EIENE_A", "1\n", 0},
+		{"readonly EIENE_A=1; readonly | grep EIENE_A", "readonly EIENE_A='1'\n", 0},
+		{"export 1A=2", "", 1},
+		{"unset -x", "", 2},
+		{"unset PATH; ls", "", 127},
+		{"cd /; cd /usr | true; pwd; echo $PWD", "/\n/\n", 0},
+		{"export EIENE_A=1; export EIENE_A=2 | true; echo $EIENE_A", "1\n", 0},
+		{"V='a  b'; export EIENE_A=$V; sh -c 'echo \"$EIENE_A\"'; export -p | grep '^export b' || echo none", "a  b\nnone\n", 0},
+		{"V='a  *'; readonly EIENE_A=$V EIENE_B=x$V; echo \"$EIENE_A|$EIENE_B\"", "a  *|xa  *\n", 0},
+		{"HOME=/home/eiene; export EIENE_A=~/bin:~/go; echo $EIENE_A", "/home/eiene/bin:/home/eiene/go\n", 0},
+		{"V='EIENE_A=1 EIENE_B=2'; export $V; echo $EIENE_A $EIENE_B", "1 2\n", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"EIENE_A=1; echo $EIENE_A", "1\n", 0},
+		{"EIENE_A=1; sh -c 'echo x$EIENE_A'", "x\n", 0},
+		{"EIENE_A=1 sh -c 'echo $EIENE_A'; echo x$EIENE_A", "1\nx\n", 0},
+		{"EIENE_A=1 EIENE_B=$EIENE_A sh -c 'echo $EIENE_B'", "1\n", 0},
+		{"EIENE_A=\"a  b\"; echo \"$EIENE_A\"", "a  b\n", 0},
+		{"false; EIENE_A=$?; echo $EIENE_A", "1\n", 0},
+		{"EIENE_A=1 true; echo $?", "0\n", 0},
+		{"set -- a b; EIENE_A=$@; echo $EIENE_A", "a b\n", 0},
+		{"readonly EIENE_A=1; EIENE_A=2", "", 1},
+		{"readonly EIENE_A=1; EIENE_A=2 echo no", "", 1},
+		{"PATH=/nonexistent-001 ls", "", 127},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestParameterExpansion(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"A=hello.tar.gz; echo $A ${A} ${#A}", "hello.tar.gz hello.tar.gz 12\n", 0},
+		{"A=hello.tar.gz; echo ${A%.*} ${A%%.*} ${A#*.} ${A##*.}", "hello.tar hello tar.gz gz\n", 0},
+		{"E=; echo ${U:-def} ${E:-def} \"[${E-def}]\" ${U-def}", "def def [] def\n", 0},
+		{"A=1; E=; echo ${A:+alt} ${E+alt} x${E:+alt} x${U+alt}", "alt alt x x\n", 0},
+		{"echo ${N:=new} $N; echo ${N:=other}", "new new\nnew\n", 0},
+		{"A=hello; echo ${A/l/L} ${A//l/L} ${A/#h/H} ${A/%o/O} ${A/l}", "heLlo heLLo Hello hellO helo\n", 0},
+		{"A=a.b.c; echo ${A//./-} ${A//[.]/}", "a-b-c abc\n", 0},
+		{"A='*.c'; P='*'; echo ${A#$P} ${A#\"$P\"} ${A#'*'}", "*.c .c .c\n", 0},
+		{"echo \"${U:-a  b}\" \"${U:-'q'}\" ${U:-'q'}", "a  b 'q' q\n", 0},
+		{"set -- a b c d e f g h i j; echo ${10} $10 ${#} ${#@}", "j a0 10 10\n", 0},
+		{"set -- a b; printf '<%s>' \"${@}\" \"${U:-$@}\"", "<a><b><a><b>", 0},
+		{"echo ${U:?not here}", "", 1},
+		{"for x in 1 2; do echo ${U:?not here}; done; echo no", "", 1},
+		{"echo ${U?}", "", 1},
+		{"echo ${A:}", "", 1},
+		{"echo ${1:=x}", "", 1},
+		{"readonly R=1; echo ${U:-$R} ${R#1}x", "1 x\n", 0},
+		{`A=dir/file.txt; echo "${A%.*}" "${A##*/}" "${A#*/}"`, "dir/file file.txt file.txt\n", 0},
+		{`A='a b c'; echo "${A%% *}" "${A/l*/X}" "${A/ /_}" "${A// /_}"`, "a a b c a_b c a_b_c\n", 0},
+		{`A=hello; echo "${A/l*/X}" "${A%'l'*}" "${A%"l*"}"`, "heX hel hello\n", 0},
+		{`A=abc; echo ${A/#/pre} ${A/%/suf} "${A/#/pre}" ${A/#x/pre} ${A/%*/all}`, "preabc abcsuf preabc abc all\n", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestParameterErrorInteractive(t *testing.T) {
+	eieneErrors := eiene_errors.NewEieneErrors(false)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
+	_interpreter.Interactive = true
+
+	output := outputHelper(t, _interpreter, eieneErrors, "echo ${U:?not here}; echo after")
+
+	if expected := "after\n"; output != expected {
+		t.Errorf("${U:?} exited an interactive shell. Got %q. Expected %q", output, expected)
+	}
+}
+
+func TestCommandSubstitution(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"echo $(echo hi) x$(printf 'a\\n\\n')y", "hi xay\n", 0},
+		{"printf '<%s>' $(printf ' a  b\\nc ') \"$(printf 'a  b\\nc')\"", "<a><b><c><a  b\nc>", 0},
+		{"printf '<%s>' x$(echo ' a ')y", "<x><a><y>", 0},
+		{"echo `echo back` \"`echo \\\"q\\\"`\" `echo \\`echo nested\\``", "back q nested\n", 0},
+		{"echo $(echo $(echo $(echo deep)))", "deep\n", 0},
+		{"echo \"$(echo \")\")\" $(echo ')')", ") )\n", 0},
+		{"A=$(echo 'a  b'); echo \"$A\"", "a  b\n", 0},
+		{"A=$(exit 3); echo $?", "3\n", 0},
+		{"A=1; B=$(A=2; echo $A); echo $A $B", "1 2\n", 0},
+		{"echo $(exit 3; echo no)x", "x\n", 0},
+		{"echo $(echo 'a' | tr a b)", "b\n", 0},
+		{"echo $(echo ;;)", "", 1},
+		{"cd /; D=$(cd /usr && pwd); echo $D; pwd", "/usr\n/\n", 0},
+		{"cd /; echo $(cd usr; echo $PWD) $PWD", "/usr /\n", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"echo $((2 ** 10)) $(( 7 % 3 )) \"$((1 << 4 | 1))\"", "1024 1 17\n", 0},
+		{"i=5; ((i++)); echo $i $((i += 2)) $i", "6 8 8\n", 0},
+		{"((0))", "", 1},
+		{"((3 > 2)) && echo yes", "yes\n", 0},
+		{"N=4; echo $(( N * $(echo 3) ))", "12\n", 0},
+		{"A=2+3; echo $((A * 2))", "10\n", 0},
+		{"echo $((1 / 0))", "", 1},
+		{"((1 +))", "", 1},
+		{"echo $((x = 2, x ? 10 : 20))$x", "102\n", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	for _, cmd := range []string{"echo $((4 / 0))", "((2 ** -1))", "readonly R=1; ((R++))"} {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		outputHelper(t, _interpreter, eieneErrors, cmd)
+
+		if !eieneErrors.HadInterpreterError {
+			t.Errorf("Interpreting (%s) was expected to report an error", cmd)
+		}
+		if _interpreter.Status() != 1 {
+			t.Errorf("Interpreting (%s) exited with %d. Expected 1", cmd, _interpreter.Status())
+		}
+	}
+}
+
+func TestGlobbing(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", ".c.txt", "d.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("EIENE_TEST_DIR", dir)
+
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"cd $EIENE_TEST_DIR; echo *.txt", "a.txt b.txt\n", 0},
+		{"cd $EIENE_TEST_DIR; echo '*'.txt \"*.txt\" \\*.txt [ab]'.'log", "*.txt *.txt *.txt [ab].log\n", 0},
+		{"cd $EIENE_TEST_DIR; P='?.*'; echo $P \"$P\"", "a.txt b.txt d.log ?.*\n", 0},
+		{"cd $EIENE_TEST_DIR; echo *.none", "*.none\n", 0},
+		{"cd $EIENE_TEST_DIR; shopt -s nullglob; echo *.none x", "x\n", 0},
+		{"cd $EIENE_TEST_DIR; shopt -s failglob; echo *.none", "", 1},
+		{"cd $EIENE_TEST_DIR; shopt -s dotglob; echo *.txt", ".c.txt a.txt b.txt\n", 0},
+		{"shopt -s nullglob globstar; shopt -u nullglob; shopt -p", "shopt -u dotglob\nshopt -u failglob\nshopt -s globstar\nshopt -u nullglob\n", 0},
+		{"shopt -q nullglob", "", 1},
+		{"shopt -s nullglob; shopt nullglob", "nullglob       \ton\n", 0},
+		{"shopt -s nope", "", 1},
+		{"echo $(cd $EIENE_TEST_DIR; echo *.txt) | cat", "a.txt b.txt\n", 0},
+		{"cd $EIENE_TEST_DIR; echo new > e.log; cat e.log <d.log; echo *.log", "new\nd.log e.log\n", 0},
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		// cd only changes the directory of the interpreter
+		if dir, _ := os.Getwd(); dir != wd {
+			t.Errorf("Interpreting (%s) changed the directory of the process to %s", test.cmd, dir)
+			os.Chdir(wd)
+		}
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestBraceExpansion(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"echo src/{api,db,web}", "src/api src/db src/web\n", 0},
+		{"echo file{,.bak} {01..03} {c..a}", "file file.bak 01 02 03 c b a\n", 0},
+		{"A=x; echo {$A,\"$A y\"}.1", "x.1 x y.1\n", 0},
+		{"printf '<%s>' {a,b}'{c,d}' {,}", "<a{c,d}><b{c,d}>", 0},
+		{"A={a,b}; echo $A", "{a,b}\n", 0},
+		{"echo {Z..c}", "Z [ \\ ] ^ _ ` a b c\n", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestTildeExpansion(t *testing.T) {
+	t.Setenv("HOME", "/home/eiene")
+	t.Setenv("OLDPWD", "/old")
+
+	root := "~root"
+	if account, err := user.Lookup("root"); err == nil {
+		root = account.HomeDir
+	}
+
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+	}{
+		{"echo ~ ~/projects ~root", "/home/eiene /home/eiene/projects " + root + "\n"},
+		{"echo ~- ~-/x", "/old /old/x\n"},
+		{"echo '~' \"~\" \\~ ~'/x' x~ ~unknown-user-001/x", "~ ~ ~ ~/x x~ ~unknown-user-001/x\n"},
+		{"P=~/bin:~/go/bin:x~; echo $P", "/home/eiene/bin:/home/eiene/go/bin:x~\n"},
+		{"echo a:~ {~,b}/c ${U:-~}", "a:~ /home/eiene/c b/c /home/eiene\n"},
+		{"HOME='/a b'; printf '<%s>' ~", "</a b>"},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+	}
+}
+
+func TestFieldSplitting(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+	}{
+		{"A=' a  b '; printf '<%s>' $A x$A\"y\" \"$A\"", "<a><b><x><a><b><y>< a  b >"},
+		{"IFS=,; L='a,,b, c,'; printf '<%s>' $L", "<a><><b>< c>"},
+		{"IFS=', '; L='a , b,,c  '; printf '<%s>' $L", "<a><b><><c>"},
+		{"IFS=:; P=/bin:/usr/bin; set -- $P; printf '<%s>' $# \"$2\"", "<2></usr/bin>"},
+		{"IFS=; A='a b'; printf '<%s>' $A", "<a b>"},
+		{"set -- a b c; IFS=-; printf '<%s>' \"$*\"; IFS=; printf '<%s>' \"$*\"", "<a-b-c><abc>"},
+		{"set -- 'a b' '' c; printf '<%s>' $@ - \"$@\"", "<a><b><c><-><a b><><c>"},
+		{"printf '<%s>' ${U:-p q} \"${U:-p q}\" $(echo 'm  n') $((12))", "<p><q><p q><m><n><12>"},
+		{"A='x y'; B=$A; printf '<%s>' \"$B\" ${A%y}z", "<x y><x><z>"},
+		{"A=''; printf '<%s>' $A \"\"$A", "<>"},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+	}
+}
+
+func TestIfCommand(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"if true; then echo yes; fi", "yes\n", 0},
+		{"if false; then echo yes; fi", "", 0},
+		{"if false; then echo a; elif false; then echo b; else echo c; fi", "c\n", 0},
+		{"if false; then :; elif true; then echo b; false; fi", "b\n", 1},
+		{"if true && false || true\nthen\n  echo multi\n  echo line\nfi", "multi\nline\n", 0},
+		{"if true; then if false; then echo a; else echo nested; fi; fi", "nested\n", 0},
+		{"if (( 2 > 1 )); then echo two; fi | tr a-z A-Z", "TWO\n", 0},
+		{"if exit 4; then echo no; fi; echo no", "", 4},
+		{"if true; then printf 'a'; fi && if false; then :; else printf 'b'; fi", "ab", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestIfCommandRedirection(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out")
+
+	eieneErrors := eiene_errors.NewEieneErrors(false)
+	_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+	outputHelper(t, _interpreter, eieneErrors, "if true; then echo a; echo b; fi > "+file)
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "a\nb\n" {
+		t.Errorf("if redirected to a file wrote %q. Expected %q", content, "a\nb\n")
+	}
+}
+
+func TestWhileLoops(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"i=0; while (( i < 3 )); do echo $i; i=$((i+1)); done", "0\n1\n2\n", 0},
+		{"i=3; until (( i == 0 )); do i=$((i-1)); echo $i; done", "2\n1\n0\n", 0},
+		{"while false; do echo no; done", "", 0},
+		{"i=0; while (( i++ < 2 )); do false; done", "", 1},
+		{"while true; do echo a; break; echo no; done; echo b", "a\nb\n", 0},
+		{"i=0; while (( i++ < 4 )); do if (( i % 2 )); then continue; fi; echo $i; done", "2\n4\n", 0},
+		{"i=0; while true; do i=$((i+1)); j=0; while true; do j=$((j+1)); if ((j == 2)); then continue 2; fi; if ((i == 3)); then break 2; fi; echo $i$j; done; done", "11\n21\n", 0},
+		{"while true; do while true; do break 5; done; echo no; done; echo out", "out\n", 0},
+		{"while true; do break 0; done", "", 1},
+		{"while true; do break x; echo no; done", "", 1},
+		{"break; echo $?", "0\n", 0},
+		{"while true; do true && break; done; echo done", "done\n", 0},
+		{"i=0; while (( i < 2 )); do echo $i; i=$((i+1)); done | tr 0-9 a-j", "a\nb\n", 0},
+		{"while true; do exit 3; done; echo no", "", 3},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{`for x in a "b c" {1..2}; do echo "<$x>"; done`, "<a>\n<b c>\n<1>\n<2>\n", 0},
+		{"A='1 2'; for x in $A; do echo $x; done; echo $x", "1\n2\n2\n", 0},
+		{`set -- a "b c"; for x; do echo "<$x>"; done`, "<a>\n<b c>\n", 0},
+		{"for x in; do echo no; done", "", 0},
+		{"for x in a b; do false; done", "", 1},
+		{"for x in a b c; do if [ $x = b ]; then continue; fi; echo $x; done", "a\nc\n", 0},
+		{"for x in 1 2; do for y in a b; do [ $y = b ] && continue 2; echo $x$y; done; done", "1a\n2a\n", 0},
+		{"for x in a b c; do echo $x; break; done", "a\n", 0},
+		{"for 1 in a; do echo no; done", "", 1},
+		{"readonly R; for R in a; do echo no; done", "", 1},
+		{"for ((i = 0; i < 3; i++)); do echo $i; done", "0\n1\n2\n", 0},
+		{"for ((i = 0; ; i++)); do (( i == 2 )) && break; echo $i; done", "0\n1\n", 0},
+		{"for ((i = 0; i < 4; i++)) do (( i % 2 )) && continue; echo $i; done", "0\n2\n", 0},
+		{"for ((;0;)); do echo no; done", "", 0},
+		{"for ((i = 0; i < ; i++)); do echo no; done", "", 1},
+		{"for x in a b; do echo $x; done | tr a-z A-Z", "A\nB\n", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestCaseCommand(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"case main.go in a|b) echo ab;; *.go) echo go;; *) echo other;; esac", "go\n", 0},
+		{"case b in a|b) echo ab;; esac", "ab\n", 0},
+		{"false; case c in a) echo a;; esac", "", 0},
+		{"case a in a) echo a; false;; esac", "a\n", 1},
+		{"case a in a) ;; esac", "", 0},
+		{"case a in (a) echo paren; esac", "paren\n", 0},
+		{"case a in esac", "", 0},
+		{"case a in a) echo 1;& b) echo 2;& c) echo 3;; d) echo 4;; esac", "1\n2\n3\n", 0},
+		{"case ab in a*) echo 1;;& *b) echo 2;;& c) echo 3;; *) echo 4;; esac", "1\n2\n4\n", 0},
+		{`case '*' in "*") echo star;; esac; case x in "*") echo no;; esac`, "star\n", 0},
+		{`P='a*'; case abc in $P) echo glob;; esac; case abc in "$P") echo no;; esac`, "glob\n", 0},
+		{`A='x y'; case $A in 'x y') echo word;; esac`, "word\n", 0},
+		{"case b in [a-c]) echo range;; esac", "range\n", 0},
+		{"case a in\n  b)\n    echo b\n    ;;\n  a)\n    echo a\n    ;;\nesac", "a\n", 0},
+		{"for i in 1 2 3; do case $i in 2) continue;; 3) break;; esac; echo $i; done", "1\n", 0},
+		{"case a in a) echo a;; esac | tr a A", "A\n", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		cmd            string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"f() { echo $# $1 $2; }; f a b", "2 a b\n", 0},
+		{"function f { echo f; }; f", "f\n", 0},
+		{"function f() { echo f; }; f", "f\n", 0},
+		{"set -- x; f() { echo $1; }; f y; echo $1", "y\nx\n", 0},
+		{"f() { return 3; echo no; }; f", "", 3},
+		{"f() { false; return; }; f", "", 1},
+		{"f() { return 300; }; f", "", 44},
+		{"f() { for i in 1 2 3; do return $i; done; }; f", "", 1},
+		{"f() { return a; }; f", "", 2},
+		{"return", "", 1},
+		{"x=g; f() { local x=l; echo $x; }; f; echo $x", "l\ng\n", 0},
+		{"f() { local x=f; g; }; g() { echo $x; }; f; echo \"[$x]\"", "f\n[]\n", 0},
+		{"f() { declare y=1; echo $y; }; f; echo \"[$y]\"", "1\n[]\n", 0},
+		{"local x=1", "", 1},
+		{"f() { echo f; }; g() { :; }; declare -F", "declare -f f\ndeclare -f g\n", 0},
+		{"f() { echo f; }; declare -f f", "f() { echo f; }\n", 0},
+		{"declare -F f", "", 1},
+		{"declare -- -p", "", 1},
+		{"declare -- --", "", 1},
+		{"EIENE_A='a b'; f() { echo f; }; declare | grep -e '^declare EIENE_A=' -e '^f'", "declare EIENE_A='a b'\n", 0},
+		{"f() { local EIENE_A=1; declare | grep '^declare EIENE_A='; }; f", "declare EIENE_A='1'\n", 0},
+		{"f() { echo f; }; unset -f f; f", "", 127},
+		{"f() { echo f; }; f=1; unset f; f", "f\n", 0},
+		{"cd() { echo mine; }; cd /", "mine\n", 0},
+		{"f() { f; }; f", "", 1},
+		{"f() { echo body; } > /dev/null; f", "", 0},
+		{"f()\n{\n  echo multi\n}\nf", "multi\n", 0},
+		{"f() if true; then echo if; fi; f", "if\n", 0},
+		{"for i in 1; do f() { break; }; f; echo $i; done", "1\n", 0},
+		{"f() { echo $X; }; X=tmp f; echo \"[$X]\"", "tmp\n[]\n", 0},
+		{"f() { exit 4; echo no; }; f; echo no", "", 4},
+		{"f() { echo piped; }; f | tr p P", "PiPed\n", 0},
+		{"{ echo a; echo b; } | tr ab AB", "A\nB\n", 0},
+		{"V='a  b'; f() { local x=$V y=-$V; echo \"$x|$y\"; }; f", "a  b|-a  b\n", 0},
+		{"V='a *'; f() { declare x=$V; echo \"$x\"; }; f; declare G=$V; echo \"$G\"", "a *\na *\n", 0},
+	}
+
+	for _, test := range tests {
+		eieneErrors := eiene_errors.NewEieneErrors(false)
+		_interpreter := interpreter.NewInterpreter(eieneErrors)
+
+		output := outputHelper(t, _interpreter, eieneErrors, test.cmd)
+
+		if output != test.expectedOutput {
+			t.Errorf("Interpreting (%s) output %q. Expected %q", test.cmd, output, test.expectedOutput)
+		}
+		if _interpreter.Status() != test.expectedStatus {
+			t.Errorf("Interpreting (%s) exited with %d. Expected %d",
+				test.cmd, _interpreter.Status(), test.expectedStatus)
+		}
+	}
+}
@@ -0,0 +1,694 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/ivf8/simp-shell/pkg/ast"
+)
+
+// A process started by a job
+type process struct {
+	cmd  *exec.Cmd
+	last bool // Started by the last command of a pipeline
+
+	stopped bool
+	done    bool
+	status  int   // Exit status, 128+N when killed or stopped by signal N
+	err     error // Why the process failed
+}
+
+// Commands run in the foreground or in the background with &. With job
+// control the processes of a job share a process group.
+type Job struct {
+	Id   int
+	Pid  int    // Process started first by the job, 0 if it started none
+	Pgid int    // Process group of the job, 0 without job control
+	Cmd  string // Source text of the command
+
+	foreground bool
+	seq        int // Jobs with a higher seq became current more recently
+
+	mutex     sync.Mutex
+	changed   *sync.Cond // Broadcast when the job or one of its processes changes state
+	processes []*process
+	running   bool // Its commands are still being run
+	status    int  // Exit status once done
+
+	startedOnce sync.Once
+	startedChan chan struct{} // Closed once a process is started or the job is done
+}
+
+func newJob(cmd string, foreground bool) *Job {
+	job := &Job{
+		Cmd:         cmd,
+		foreground:  foreground,
+		running:     true,
+		startedChan: make(chan struct{}),
+	}
+	job.changed = sync.NewCond(&job.mutex)
+
+	return job
+}
+
+// Records the process started by the job. Only the first one is kept.
+func (j *Job) started(pid int) {
+	j.startedOnce.Do(func() {
+		j.Pid = pid
+		close(j.startedChan)
+	})
+}
+
+// Starts cmd as a process of the job. With job control the process joins
+// the process group of the job, or leads it if it is the first one.
+func (j *Job) start(cmd *exec.Cmd, t *terminal, last bool) (*process, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if t != nil {
+		t.setProcessGroup(cmd, j.Pgid, j.foreground)
+	}
+
+	err := cmd.Start()
+	// The process group is gone once all its processes have exited
+	if errors.Is(err, syscall.EPERM) && t != nil && j.Pgid != 0 {
+		retry := exec.Command(cmd.Path, cmd.Args[1:]...)
+		retry.Args, retry.Env, retry.Dir = cmd.Args, cmd.Env, cmd.Dir
+		retry.Stdin, retry.Stdout, retry.Stderr = cmd.Stdin, cmd.Stdout, cmd.Stderr
+		t.setProcessGroup(retry, 0, j.foreground)
+
+		cmd = retry
+		err = cmd.Start()
+		if err == nil {
+			j.Pgid = 0
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pid := cmd.Process.Pid
+	if t != nil && j.Pgid == 0 {
+		j.Pgid = pid
+	}
+	j.started(pid)
+
+	p := &process{cmd: cmd, last: last}
+	j.processes = append(j.processes, p)
+	go j.watch(p)
+
+	return p, nil
+}
+
+// Records the state changes of p until it exits.
+func (j *Job) watch(p *process) {
+	for {
+		status, stopped, err := waitProcess(p.cmd)
+
+		j.mutex.Lock()
+		p.status, p.stopped, p.err = status, stopped, err
+		if !stopped {
+			p.done = true
+			// The job was stopped and continued after its commands returned
+			if p.last && !j.running {
+				j.status = status
+			}
+		}
+		j.changed.Broadcast()
+		j.mutex.Unlock()
+
+		if !stopped {
+			return
+		}
+	}
+}
+
+// Waits for p to exit or stop. Returns its exit status and why it failed.
+func (j *Job) wait(p *process) (int, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	for !p.done && !p.stopped {
+		j.changed.Wait()
+	}
+
+	return p.status, p.err
+}
+
+// Marks the commands of the job as returned with the exit status of the
+// last one.
+func (j *Job) finish(status int) {
+	j.mutex.Lock()
+	j.running = false
+	j.status = status
+	j.changed.Broadcast()
+	j.mutex.Unlock()
+
+	j.started(0)
+}
+
+// Waits for the job to be done or stopped. Returns its exit status, or
+// that of the stopped process, and whether it was stopped.
+func (j *Job) waitDoneOrStopped() (int, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	for {
+		for _, p := range j.processes {
+			if p.stopped && !p.done {
+				return p.status, true
+			}
+		}
+		if j.doneLocked() {
+			return j.status, false
+		}
+
+		j.changed.Wait()
+	}
+}
+
+// Marks the stopped processes of the job as running again before they are
+// sent SIGCONT.
+func (j *Job) continued(foreground bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.foreground = foreground
+	for _, p := range j.processes {
+		p.stopped = false
+	}
+}
+
+// Returns the process group of the job, 0 if it has none
+func (j *Job) processGroup() int {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.Pgid
+}
+
+func (j *Job) doneLocked() bool {
+	if j.running {
+		return false
+	}
+
+	for _, p := range j.processes {
+		if !p.done {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (j *Job) stoppedLocked() bool {
+	for _, p := range j.processes {
+		if p.stopped && !p.done {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (j *Job) isDone() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.doneLocked()
+}
+
+func (j *Job) isStopped() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.stoppedLocked()
+}
+
+// Returns the state of the job as shown by the jobs builtin
+func (j *Job) state() string {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	switch {
+	case j.stoppedLocked():
+		return "Stopped"
+	case !j.doneLocked():
+		return "Running"
+	case j.status != 0:
+		return fmt.Sprintf("Exit %d", j.status)
+	default:
+		return "Done"
+	}
+}
+
+// Jobs run by an interpreter. Jobs are added from the interpreter and
+// finished from the goroutines running them.
+type JobTable struct {
+	mutex sync.Mutex
+	jobs  []*Job
+	seq   int
+}
+
+func NewJobTable() *JobTable {
+	return &JobTable{
+		jobs: []*Job{},
+	}
+}
+
+// Adds job to the table as the current job. Its id is one more than the
+// highest one in use.
+func (t *JobTable) add(job *Job) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	job.Id = 1
+	if len(t.jobs) > 0 {
+		job.Id = t.jobs[len(t.jobs)-1].Id + 1
+	}
+	t.jobs = append(t.jobs, job)
+
+	t.seq++
+	job.seq = t.seq
+}
+
+// Makes job the current job eg when it is stopped or continued
+func (t *JobTable) makeCurrent(job *Job) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.seq++
+	job.seq = t.seq
+}
+
+func (t *JobTable) remove(job *Job) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for n, j := range t.jobs {
+		if j == job {
+			t.jobs = append(t.jobs[:n], t.jobs[n+1:]...)
+			return
+		}
+	}
+}
+
+// Returns the current (+) and previous (-) jobs. Stopped jobs come before
+// running ones, then the ones that became current last.
+func (t *JobTable) currentLocked() (*Job, *Job) {
+	outranks := func(a, b *Job) bool {
+		aStopped, bStopped := a.isStopped(), b.isStopped()
+		if aStopped != bStopped {
+			return aStopped
+		}
+		return a.seq > b.seq
+	}
+
+	var current, previous *Job
+	for _, job := range t.jobs {
+		switch {
+		case current == nil || outranks(job, current):
+			current, previous = job, current
+		case previous == nil || outranks(job, previous):
+			previous = job
+		}
+	}
+
+	return current, previous
+}
+
+// Returns + for the current job, - for the previous one and a space for
+// the others
+func (t *JobTable) markLocked(job *Job) byte {
+	current, previous := t.currentLocked()
+	switch job {
+	case current:
+		return '+'
+	case previous:
+		return '-'
+	default:
+		return ' '
+	}
+}
+
+// Returns the jobs in the table with their marks
+func (t *JobTable) list() ([]*Job, []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	jobs := append([]*Job{}, t.jobs...)
+	marks := []byte{}
+	for _, job := range jobs {
+		marks = append(marks, t.markLocked(job))
+	}
+
+	return jobs, marks
+}
+
+// Returns the jobs that are stopped
+func (t *JobTable) stopped() []*Job {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stopped := []*Job{}
+	for _, job := range t.jobs {
+		if job.isStopped() {
+			stopped = append(stopped, job)
+		}
+	}
+
+	return stopped
+}
+
+// Removes the jobs that are done from the table and returns them with
+// their marks.
+func (t *JobTable) removeDone() ([]*Job, []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	done := []*Job{}
+	marks := []byte{}
+	running := []*Job{}
+	for _, job := range t.jobs {
+		if job.isDone() {
+			done = append(done, job)
+			marks = append(marks, t.markLocked(job))
+		} else {
+			running = append(running, job)
+		}
+	}
+	t.jobs = running
+
+	return done, marks
+}
+
+// Finds the job referred to by spec: %n or n for job n, %+, %% or nothing
+// for the current job, %- for the previous one and %string for the job
+// whose command starts with string.
+func (t *JobTable) find(spec string) (*Job, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	current, previous := t.currentLocked()
+	name := strings.TrimPrefix(spec, "%")
+
+	switch name {
+	case "", "+", "%":
+		if current == nil {
+			return nil, errors.New("current: no such job")
+		}
+		return current, nil
+
+	case "-":
+		if previous == nil {
+			return nil, fmt.Errorf("%s: no such job", spec)
+		}
+		return previous, nil
+	}
+
+	if id, err := strconv.Atoi(name); err == nil {
+		for _, job := range t.jobs {
+			if job.Id == id {
+				return job, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	var found *Job
+	for _, job := range t.jobs {
+		if strings.HasPrefix(job.Cmd, name) {
+			if found != nil {
+				return nil, fmt.Errorf("%s: ambiguous job spec", spec)
+			}
+			found = job
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	return found, nil
+}
+
+// Turns on job control: every job gets its own process group and the
+// foreground one is given the terminal. Fails if the shell is not
+// attached to a terminal.
+func (i *Interpreter) EnableJobControl() error {
+	t, err := newTerminal()
+	if err != nil {
+		return err
+	}
+
+	i.terminal = t
+
+	// Ctrl-C stops the loop being run instead of the shell
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		for range interrupts {
+			i.interrupted.Store(true)
+		}
+	}()
+
+	return nil
+}
+
+// Runs cmd as a foreground job. If it gets stopped eg with Ctrl-Z, it is
+// added to the job table.
+func (i *Interpreter) runForeground(cmd ast.Cmd) {
+	job := newJob(ast.NewAstFormatter([]ast.Cmd{cmd}).Format(), true)
+
+	i.job = job
+	i.interrupted.Store(false)
+	cmd.Accept(i)
+	i.job = nil
+
+	job.finish(i.status)
+	if i.terminal != nil && job.processGroup() != 0 {
+		i.terminal.takeBack()
+	}
+
+	if job.isStopped() {
+		i.jobs.add(job)
+		fmt.Fprintln(i.Stderr)
+		i.printJob(job)
+	}
+}
+
+// Starts cmd without waiting for it to finish and prints its job id and
+// process id eg [1] 12345, or only its job id if it does not start a
+// process right away. The job runs in a subshell so that builtins such as
+// cd do not change the shell.
+func (i *Interpreter) VisitBackgroundCmd(cmd *ast.BackgroundCmd) any {
+	job := newJob(ast.NewAstFormatter([]ast.Cmd{cmd.Cmd}).Format(), false)
+	i.jobs.add(job)
+
+	child := i.subshell()
+	child.job = job
+
+	// Without job control, background commands must not read what is
+	// typed at the prompt. With it they are stopped when they try to.
+	var devNull *os.File
+	if i.terminal == nil {
+		if file, err := os.Open(os.DevNull); err == nil {
+			devNull = file
+			child.Stdin = devNull
+		}
+	}
+
+	go func() {
+		cmd.Cmd.Accept(child)
+		if devNull != nil {
+			devNull.Close()
+		}
+
+		job.finish(child.status)
+	}()
+
+	i.status = 0
+
+	// Only a job starting its process right away is waited for, so that its
+	// pid is known for $!. Other jobs may run builtins for long before.
+	if !i.startsProcess(cmd.Cmd) {
+		fmt.Fprintf(i.Stderr, "[%d]\n", job.Id)
+		return nil
+	}
+
+	<-job.startedChan
+	if job.Pid == 0 {
+		fmt.Fprintf(i.Stderr, "[%d]\n", job.Id)
+		return nil
+	}
+
+	fmt.Fprintf(i.Stderr, "[%d] %d\n", job.Id, job.Pid)
+	i.lastBackgroundPid = job.Pid
+
+	return nil
+}
+
+// Checks if cmd starts a process as soon as it is run, ie it is a program
+// or a pipeline of programs without command substitutions to run first.
+func (i *Interpreter) startsProcess(cmd ast.Cmd) bool {
+	switch cmd := cmd.(type) {
+	case *ast.PipelineCmd:
+		for _, stage := range cmd.Cmds {
+			if !i.startsProcess(stage) {
+				return false
+			}
+		}
+		return true
+
+	case *ast.PrimaryCmd:
+		name := cmd.ProgramName
+		if name.Type == "" || name.Raw != name.Lexeme || strings.ContainsAny(name.Raw, "$`{~*?[") {
+			return false
+		}
+		if BUILTINS_MAP[name.Lexeme] || i.functions[name.Lexeme] != nil {
+			return false
+		}
+
+		words := append(slices.Clone(cmd.Assignments), cmd.Arguments...)
+		for _, redirection := range cmd.Redirections {
+			words = append(words, redirection.Target)
+		}
+		for _, word := range words {
+			if strings.Contains(word.Raw, "$(") || strings.Contains(word.Raw, "`") {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// Reports the background jobs that finished since the last call
+// eg [1]+  Done                    sleep 1
+func (i *Interpreter) ReportDoneJobs() {
+	jobs, marks := i.jobs.removeDone()
+	for n, job := range jobs {
+		fmt.Fprintf(i.Stderr, "[%d]%c  %-24s%s\n", job.Id, marks[n], job.state(), job.Cmd)
+	}
+}
+
+// Prints the state of job eg [1]+  Stopped                 vim
+func (i *Interpreter) printJob(job *Job) {
+	i.jobs.mutex.Lock()
+	mark := i.jobs.markLocked(job)
+	i.jobs.mutex.Unlock()
+
+	fmt.Fprintf(i.Stderr, "[%d]%c  %-24s%s\n", job.Id, mark, job.state(), job.Cmd)
+}
+
+// Execute jobs builtin command
+func (i *Interpreter) listJobs() {
+	jobs, marks := i.jobs.list()
+	for n, job := range jobs {
+		state := job.state()
+
+		cmd := job.Cmd
+		if state == "Running" {
+			cmd += " &"
+		}
+
+		fmt.Fprintf(i.Stdout, "[%d]%c  %-24s%s\n", job.Id, marks[n], state, cmd)
+	}
+}
+
+// Execute fg builtin command
+func (i *Interpreter) fg(cmd *ast.PrimaryCmd, args []string) {
+	job := i.findJob(cmd, "fg", args)
+	if job == nil {
+		return
+	}
+
+	fmt.Fprintln(i.Stdout, job.Cmd)
+
+	job.continued(true)
+	if pgid := job.processGroup(); pgid != 0 {
+		i.terminal.setForeground(pgid)
+		i.terminal.continueGroup(pgid)
+	}
+
+	status, stopped := job.waitDoneOrStopped()
+	i.terminal.takeBack()
+
+	i.status = status
+	if stopped {
+		i.jobs.makeCurrent(job)
+		fmt.Fprintln(i.Stderr)
+		i.printJob(job)
+	} else {
+		i.jobs.remove(job)
+	}
+}
+
+// Execute bg builtin command
+func (i *Interpreter) bg(cmd *ast.PrimaryCmd, args []string) {
+	job := i.findJob(cmd, "bg", args)
+	if job == nil {
+		return
+	}
+
+	if !job.isStopped() {
+		fmt.Fprintf(i.Stderr, "bg: job %d already in background\n", job.Id)
+		return
+	}
+
+	job.continued(false)
+	if pgid := job.processGroup(); pgid != 0 {
+		i.terminal.continueGroup(pgid)
+	}
+	i.jobs.makeCurrent(job)
+
+	fmt.Fprintf(i.Stdout, "[%d]+ %s &\n", job.Id, job.Cmd)
+}
+
+// Execute disown builtin command. The job is forgotten without being
+// stopped.
+func (i *Interpreter) disown(cmd *ast.PrimaryCmd, args []string) {
+	job, err := i.jobs.find(jobSpec(args))
+	if err != nil {
+		i.eieneErrors.InterpreterError(cmd.Pos(), "disown: "+err.Error())
+		i.status = 1
+		return
+	}
+
+	i.jobs.remove(job)
+}
+
+// Finds the job given to fg or bg. Reports an error and returns nil if
+// there is none or job control is off.
+func (i *Interpreter) findJob(cmd *ast.PrimaryCmd, builtin string, args []string) *Job {
+	if i.terminal == nil {
+		i.eieneErrors.InterpreterError(cmd.Pos(), builtin+": no job control")
+		i.status = 1
+		return nil
+	}
+
+	job, err := i.jobs.find(jobSpec(args))
+	if err != nil {
+		i.eieneErrors.InterpreterError(cmd.Pos(), builtin+": "+err.Error())
+		i.status = 1
+		return nil
+	}
+
+	return job
+}
+
+// Returns the job spec given to a builtin, empty for the current job
+func jobSpec(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
@@ -0,0 +1,409 @@
+package interpreter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ivf8/simp-shell/pkg/pattern"
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Writes the value of the parameter name eg HOME, 1 or @ to value.
+func (i *Interpreter) writeParameter(name string, value *fields, quoted bool) {
+	switch {
+	// Joined by the first character of IFS
+	case name == "*" && quoted:
+		separator := ""
+		for _, c := range i.ifs() {
+			separator = string(c)
+			break
+		}
+		value.writeQuoted(strings.Join(i.positional, separator))
+
+	case name == "@" || name == "*":
+		i.expandPositional(value, quoted)
+
+	default:
+		parameter, _ := i.parameter(name)
+		value.writeSplit(parameter, quoted)
+	}
+}
+
+// Writes the positional parameters to value, each one in its own field.
+// Unless quoted, empty parameters are left out and the others are split.
+func (i *Interpreter) expandPositional(value *fields, quoted bool) {
+	if quoted {
+		value.positional++
+	}
+
+	first := true
+	for _, param := range i.positional {
+		if !quoted && param == "" {
+			continue
+		}
+		if !first {
+			value.end()
+		}
+		value.writeSplit(param, quoted)
+		first = false
+	}
+}
+
+// Returns the value of the parameter name and whether it is set. The
+// parameter is a variable, a positional parameter or a special one eg ?.
+func (i *Interpreter) parameter(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(i.status), true
+
+	case "!":
+		if i.lastBackgroundPid == 0 {
+			return "", false
+		}
+		return strconv.Itoa(i.lastBackgroundPid), true
+
+	case "#":
+		return strconv.Itoa(len(i.positional)), true
+
+	case "0":
+		return i.scriptName, true
+
+	case "@", "*":
+		return strings.Join(i.positional, " "), len(i.positional) > 0
+	}
+
+	if isNumber(name) {
+		n, err := strconv.Atoi(name)
+		if err != nil || n < 1 || n > len(i.positional) {
+			return "", false
+		}
+		return i.positional[n-1], true
+	}
+
+	return i.env.Get(name)
+}
+
+// Expands the parameter expansion ${expr} and writes it to value. expr
+// is one of
+//
+//	NAME  #NAME          the value and its length
+//	NAME:-word NAME-word  word if NAME is unset or empty, only unset without :
+//	NAME:=word NAME=word  same but NAME is also set to word
+//	NAME:?word NAME?word  error with message word
+//	NAME:+word NAME+word  word if NAME is set and not empty
+//	NAME#pat NAME##pat    the value without its shortest or longest prefix matching pat
+//	NAME%pat NAME%%pat    same for suffixes
+//	NAME/pat/rep          the value with the first match of pat replaced by rep,
+//	                      all matches with //, at the start with /# and the end with /%
+//
+// Returns false if the expansion failed.
+func (i *Interpreter) expandParameter(expr []rune, value *fields, quoted bool, pos token.Position) bool {
+	badSubstitution := func() bool {
+		i.eieneErrors.InterpreterError(pos, "${"+string(expr)+"}: bad substitution")
+		return false
+	}
+
+	// Length
+	if len(expr) > 1 && expr[0] == '#' {
+		name := string(expr[1:])
+		if parameterNameEnd(expr[1:]) != len(expr)-1 {
+			return badSubstitution()
+		}
+
+		length := len(i.positional)
+		if name != "@" && name != "*" {
+			parameter, _ := i.parameter(name)
+			length = len([]rune(parameter))
+		}
+		value.writeSplit(strconv.Itoa(length), quoted)
+		return true
+	}
+
+	nameEnd := parameterNameEnd(expr)
+	if nameEnd == 0 {
+		return badSubstitution()
+	}
+	name := string(expr[:nameEnd])
+
+	rest := expr[nameEnd:]
+	if len(rest) == 0 {
+		i.writeParameter(name, value, quoted)
+		return true
+	}
+
+	colon := rest[0] == ':'
+	if colon {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return badSubstitution()
+	}
+	operator, operand := rest[0], rest[1:]
+
+	parameter, set := i.parameter(name)
+	unset := !set || (colon && parameter == "")
+
+	switch operator {
+	case '-':
+		if unset {
+			return i.expandOperand(operand, value, quoted, pos)
+		}
+		i.writeParameter(name, value, quoted)
+
+	case '=':
+		if !unset {
+			i.writeParameter(name, value, quoted)
+			return true
+		}
+
+		word, ok := i.expandString(operand, quoted, pos)
+		if !ok {
+			return false
+		}
+		if !isName(name) {
+			i.eieneErrors.InterpreterError(pos, "$"+name+": cannot assign in this way")
+			return false
+		}
+		if err := i.env.Set(name, word); err != nil {
+			i.eieneErrors.InterpreterError(pos, name+": "+err.Error())
+			return false
+		}
+		value.writeSplit(word, quoted)
+
+	case '?':
+		if !unset {
+			i.writeParameter(name, value, quoted)
+			return true
+		}
+
+		message := "parameter null or not set"
+		if len(operand) > 0 {
+			word, ok := i.expandString(operand, quoted, pos)
+			if !ok {
+				return false
+			}
+			message = word
+		}
+		i.eieneErrors.InterpreterError(pos, name+": "+message)
+
+		// A script cannot go on without the parameter
+		if !i.Interactive {
+			i.exiting = true
+			i.eieneErrors.ExitError()
+		}
+		return false
+
+	case '+':
+		if !unset {
+			return i.expandOperand(operand, value, quoted, pos)
+		}
+
+	case '#', '%':
+		if colon {
+			return badSubstitution()
+		}
+
+		longest := len(operand) > 0 && operand[0] == operator
+		if longest {
+			operand = operand[1:]
+		}
+		// The pattern is its own quoting context, as in "${A%.*}"
+		_pattern, ok := i.expandPattern(operand, false, pos)
+		if !ok {
+			return false
+		}
+
+		if operator == '#' {
+			value.writeSplit(removePrefix(parameter, _pattern, longest), quoted)
+		} else {
+			value.writeSplit(removeSuffix(parameter, _pattern, longest), quoted)
+		}
+
+	case '/':
+		if colon {
+			return badSubstitution()
+		}
+
+		mode := rune(0)
+		if len(operand) > 0 && (operand[0] == '/' || operand[0] == '#' || operand[0] == '%') {
+			mode = operand[0]
+			operand = operand[1:]
+		}
+
+		patternText, replacementText := splitReplacement(operand)
+		_pattern, ok := i.expandPattern(patternText, false, pos)
+		if !ok {
+			return false
+		}
+		replacement, ok := i.expandString(replacementText, quoted, pos)
+		if !ok {
+			return false
+		}
+
+		value.writeSplit(replace(parameter, _pattern, replacement, mode), quoted)
+
+	default:
+		return badSubstitution()
+	}
+
+	return true
+}
+
+// Expands the word of ${VAR:-word} or ${VAR:+word} to value. Unless quoted,
+// the text of word is split as the expansions in it.
+func (i *Interpreter) expandOperand(operand []rune, value *fields, quoted bool, pos token.Position) bool {
+	splitText := value.splitText
+	value.splitText = true
+	defer func() { value.splitText = splitText }()
+
+	return i.expand(operand, value, quoted, pos)
+}
+
+// Returns the length of the parameter name at the start of expr eg 3 for
+// HOME in HOME:-x, 2 for 10 in 10 and 1 for ? in ?. 0 if there is none.
+func parameterNameEnd(expr []rune) int {
+	if len(expr) == 0 {
+		return 0
+	}
+
+	switch {
+	case isNameStart(expr[0]):
+		n := 1
+		for n < len(expr) && isNameChar(expr[n]) {
+			n++
+		}
+		return n
+
+	case expr[0] >= '0' && expr[0] <= '9':
+		n := 1
+		for n < len(expr) && expr[n] >= '0' && expr[n] <= '9' {
+			n++
+		}
+		return n
+
+	case isSpecialParameter(expr[0]):
+		return 1
+	}
+
+	return 0
+}
+
+// Splits the operand of ${NAME/pat/rep} at the first unquoted / into the
+// pattern and the replacement.
+func splitReplacement(operand []rune) ([]rune, []rune) {
+	for n := 0; n < len(operand); n++ {
+		switch {
+		case operand[n] == '\\':
+			n++
+
+		case operand[n] == '"' || operand[n] == '\'':
+			n = quoteEnd(operand, n)
+
+		case operand[n] == '$' && n+1 < len(operand) && operand[n+1] == '(':
+			n = substitutionEnd(operand, n) - 1
+
+		case operand[n] == '$' && n+1 < len(operand) && operand[n+1] == '{':
+			n = braceEnd(operand, n, false) - 1
+
+		case operand[n] == '/':
+			return operand[:n], operand[n+1:]
+		}
+	}
+
+	return operand, nil
+}
+
+// Returns s without its shortest or longest prefix matching p
+func removePrefix(s string, p *pattern.Pattern, longest bool) string {
+	runes := []rune(s)
+
+	for n := range len(runes) + 1 {
+		k := n
+		if longest {
+			k = len(runes) - n
+		}
+		if p.Match(string(runes[:k])) {
+			return string(runes[k:])
+		}
+	}
+
+	return s
+}
+
+// Returns s without its shortest or longest suffix matching p
+func removeSuffix(s string, p *pattern.Pattern, longest bool) string {
+	runes := []rune(s)
+
+	for n := range len(runes) + 1 {
+		k := len(runes) - n
+		if longest {
+			k = n
+		}
+		if p.Match(string(runes[k:])) {
+			return string(runes[:k])
+		}
+	}
+
+	return s
+}
+
+// Returns s with the longest match of p replaced by replacement. mode is
+// / to replace all matches, # to only replace a match at the start, % at
+// the end, 0 to replace the first match.
+func replace(s string, p *pattern.Pattern, replacement string, mode rune) string {
+	runes := []rune(s)
+
+	// An anchored pattern can match the empty string eg ${A/#/pre}
+	switch mode {
+	case '#':
+		for k := len(runes); k >= 0; k-- {
+			if p.Match(string(runes[:k])) {
+				return replacement + string(runes[k:])
+			}
+		}
+		return s
+
+	case '%':
+		for k := range len(runes) + 1 {
+			if p.Match(string(runes[k:])) {
+				return string(runes[:k]) + replacement
+			}
+		}
+		return s
+	}
+
+	replaced := strings.Builder{}
+
+	n := 0
+	for n < len(runes) {
+		// Longest match starting at n
+		end := -1
+		for k := len(runes); k > n; k-- {
+			if p.Match(string(runes[n:k])) {
+				end = k
+				break
+			}
+		}
+
+		if end < 0 {
+			replaced.WriteRune(runes[n])
+			n++
+			continue
+		}
+
+		replaced.WriteString(replacement)
+		n = end
+		if mode != '/' {
+			break
+		}
+	}
+
+	replaced.WriteString(string(runes[n:]))
+	return replaced.String()
+}
+
+// Checks if c names a special parameter eg ? in $? or a positional one
+// eg 1 in $1.
+func isSpecialParameter(c rune) bool {
+	return c == '?' || c == '!' || c == '#' || c == '@' || c == '*' || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,138 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ivf8/simp-shell/pkg/ast"
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Flags used to open the file redirected to by each operator
+var REDIRECTION_FLAGS = map[token.TokenType]int{
+	token.LESS:      os.O_RDONLY,
+	token.GREAT:     os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	token.DGREAT:    os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	token.LESSGREAT: os.O_RDWR | os.O_CREATE,
+	token.ANDGREAT:  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	token.ANDDGREAT: os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+}
+
+// Applies redirections to the streams of the interpreter in the order
+// they are written eg >out 2>&1 sends both stdout and stderr to out.
+// Returns a function closing the opened files and restoring the previous
+// streams, and false if a redirection failed.
+func (i *Interpreter) redirect(redirections []ast.Redirection) (func(), bool) {
+	stdin, stdout, stderr := i.Stdin, i.Stdout, i.Stderr
+	output := i.eieneErrors.Output
+	opened := []*os.File{}
+
+	restore := func() {
+		for _, file := range opened {
+			file.Close()
+		}
+		i.Stdin, i.Stdout, i.Stderr = stdin, stdout, stderr
+		i.eieneErrors.Output = output
+	}
+
+	streams := []**os.File{&i.Stdin, &i.Stdout, &i.Stderr}
+
+	for _, redirection := range redirections {
+		operator := redirection.Operator
+		targets, ok := i.expandWord(redirection.Target)
+		if !ok {
+			return restore, false
+		}
+		if len(targets) != 1 {
+			i.eieneErrors.InterpreterError(operator.Pos, redirection.Target.Raw+": ambiguous redirect")
+			return restore, false
+		}
+		target := targets[0]
+
+		fd := 1
+		if operator.Type == token.LESS || operator.Type == token.LESSGREAT || operator.Type == token.LESSAND {
+			fd = 0
+		}
+		if fdText := strings.TrimRight(operator.Lexeme, "<>&"); fdText != "" {
+			fd, _ = strconv.Atoi(fdText)
+		}
+		if fd >= len(streams) {
+			i.eieneErrors.InterpreterError(operator.Pos, fmt.Sprintf("%d: Bad file descriptor", fd))
+			return restore, false
+		}
+
+		tokenType := operator.Type
+		if tokenType == token.GREATAND || tokenType == token.LESSAND {
+			// Close a file descriptor eg 2>&-. Programs are started with
+			// it closed and builtins fail writing to it.
+			if target == "-" {
+				*streams[fd] = nil
+				continue
+			}
+
+			// Duplicate a file descriptor eg 2>&1
+			if isNumber(target) {
+				targetFd, _ := strconv.Atoi(target)
+				if targetFd >= len(streams) {
+					i.eieneErrors.InterpreterError(operator.Pos, target+": Bad file descriptor")
+					return restore, false
+				}
+
+				*streams[fd] = *streams[targetFd]
+				continue
+			}
+
+			if tokenType == token.LESSAND {
+				i.eieneErrors.InterpreterError(operator.Pos, target+": ambiguous redirect")
+				return restore, false
+			}
+
+			// >&file is the same as &>file
+			tokenType = token.ANDGREAT
+		}
+
+		file, err := os.OpenFile(i.absPath(target), REDIRECTION_FLAGS[tokenType], 0666)
+		if err != nil {
+			var pathErr *fs.PathError
+			if errors.As(err, &pathErr) {
+				err = fmt.Errorf("%s: %w", target, pathErr.Err)
+			}
+
+			i.eieneErrors.InterpreterError(operator.Pos, err.Error())
+			return restore, false
+		}
+		opened = append(opened, file)
+
+		if tokenType == token.ANDGREAT || tokenType == token.ANDDGREAT {
+			i.Stdout = file
+			i.Stderr = file
+		} else {
+			*streams[fd] = file
+		}
+	}
+
+	// Errors of builtins honor the redirections too
+	if i.Stderr != stderr {
+		i.eieneErrors.Output = i.Stderr
+	}
+
+	return restore, true
+}
+
+// Checks if value is made up of digits only.
+func isNumber(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
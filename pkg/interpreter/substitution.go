@@ -0,0 +1,85 @@
+package interpreter
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ivf8/simp-shell/pkg/parser"
+	"github.com/ivf8/simp-shell/pkg/scanner"
+	"github.com/ivf8/simp-shell/pkg/token"
+)
+
+// Runs the commands in source in a subshell and returns what they wrote
+// to stdout without its trailing newlines. Returns false if source could
+// not be read or run.
+func (i *Interpreter) substitute(source string, pos token.Position) (string, bool) {
+	sub := i.subshell()
+	sub.piped = true
+
+	tokens := scanner.NewScanner(source, sub.eieneErrors, nil).ScanTokens()
+	if sub.eieneErrors.HadIncompleteInput {
+		sub.eieneErrors.Report(sub.eieneErrors.Errors[len(sub.eieneErrors.Errors)-1])
+	}
+	if sub.eieneErrors.HadError {
+		i.substitutionStatus = 2
+		return "", false
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		i.eieneErrors.InterpreterError(pos, err.Error())
+		return "", false
+	}
+	sub.Stdout = writer
+
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		output <- data
+	}()
+
+	for _, cmd := range parser.NewParser(tokens).Parse() {
+		cmd.Accept(sub)
+		if sub.exiting {
+			break
+		}
+	}
+	writer.Close()
+
+	i.substitutionStatus = sub.status
+	return strings.TrimRight(string(<-output), "\n"), true
+}
+
+// Returns the index after the ` closing the one at start
+func backquoteEnd(raw []rune, start int) int {
+	for n := start + 1; n < len(raw); n++ {
+		switch raw[n] {
+		case '\\':
+			n++
+		case '`':
+			return n + 1
+		}
+	}
+
+	return len(raw)
+}
+
+// Returns the commands of a `...` command substitution. A back slash
+// only escapes $, ` and \, and " between double quotes.
+func unescapeBackquoted(source []rune, inDoubleQuotes bool) string {
+	unescaped := strings.Builder{}
+
+	for n := 0; n < len(source); n++ {
+		if source[n] == '\\' && n+1 < len(source) {
+			switch next := source[n+1]; {
+			case next == '$' || next == '`' || next == '\\' || (next == '"' && inDoubleQuotes):
+				n++
+			}
+		}
+		unescaped.WriteRune(source[n])
+	}
+
+	return unescaped.String()
+}
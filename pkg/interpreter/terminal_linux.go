@@ -0,0 +1,134 @@
+//go:build linux
+
+package interpreter
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Controlling terminal of an interactive shell running with job control
+type terminal struct {
+	fd    int           // Descriptor of the terminal
+	pgid  int           // Process group of the shell
+	modes *unix.Termios // Modes of the terminal used by the shell
+}
+
+// Puts the shell in its own process group in the foreground of the
+// terminal on its standard input. Fails if the input is not a terminal.
+func newTerminal() (*terminal, error) {
+	fd := int(os.Stdin.Fd())
+	modes, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, errors.New("standard input is not a terminal")
+	}
+
+	// Wait until started in the foreground
+	for {
+		pgid, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+		if err != nil {
+			return nil, err
+		}
+		if pgid == unix.Getpgrp() {
+			break
+		}
+		unix.Kill(-unix.Getpgrp(), unix.SIGTTIN)
+	}
+
+	// Catching the signals rather than ignoring them lets the commands
+	// started get the default behaviour back
+	signal.Notify(make(chan os.Signal, 1), unix.SIGTSTP, unix.SIGTTIN, unix.SIGTTOU)
+
+	pid := unix.Getpid()
+	if unix.Getpgrp() != pid {
+		if err := unix.Setpgid(pid, pid); err != nil {
+			return nil, err
+		}
+	}
+
+	t := &terminal{fd: fd, pgid: pid, modes: modes}
+	if err := t.setForeground(pid); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Gives the terminal to the process group pgid
+func (t *terminal) setForeground(pgid int) error {
+	// The shell is in the background while doing this and would be sent
+	// SIGTTOU unless the signal is blocked
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var blocked, previous unix.Sigset_t
+	blocked.Val[0] |= 1 << (unix.SIGTTOU - 1)
+	unix.PthreadSigmask(unix.SIG_BLOCK, &blocked, &previous)
+	defer unix.PthreadSigmask(unix.SIG_SETMASK, &previous, nil)
+
+	return unix.IoctlSetPointerInt(t.fd, unix.TIOCSPGRP, pgid)
+}
+
+// Takes the terminal back from the foreground job and restores the modes
+// the job may have changed
+func (t *terminal) takeBack() {
+	t.setForeground(t.pgid)
+	unix.IoctlSetTermios(t.fd, unix.TCSETSW, t.modes)
+}
+
+// Makes cmd join the process group pgid, or start a new one if pgid is 0.
+// A foreground process group is given the terminal.
+func (t *terminal) setProcessGroup(cmd *exec.Cmd, pgid int, foreground bool) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:    true,
+		Pgid:       pgid,
+		Foreground: foreground,
+		Ctty:       t.fd,
+	}
+}
+
+// Sends SIGCONT to the process group pgid
+func (t *terminal) continueGroup(pgid int) error {
+	return unix.Kill(-pgid, unix.SIGCONT)
+}
+
+// Sends SIGHUP to the process group pgid and wakes it up to receive it
+func (t *terminal) hangUpGroup(pgid int) {
+	unix.Kill(-pgid, unix.SIGHUP)
+	unix.Kill(-pgid, unix.SIGCONT)
+}
+
+// Waits for the process started by cmd to exit or stop. Returns its exit
+// status, 128+N if it was killed or stopped by signal N, and whether it
+// was stopped. Fails only if the process could not be waited for.
+func waitProcess(cmd *exec.Cmd) (int, bool, error) {
+	for {
+		var status syscall.WaitStatus
+		_, err := syscall.Wait4(cmd.Process.Pid, &status, syscall.WUNTRACED, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return 1, false, err
+		}
+
+		switch {
+		case status.Stopped():
+			return 128 + int(status.StopSignal()), true, nil
+
+		case status.Signaled():
+			cmd.Process.Release()
+			return 128 + int(status.Signal()), false, nil
+
+		default:
+			cmd.Process.Release()
+			return status.ExitStatus(), false, nil
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build !linux
+
+package interpreter
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Job control is only supported on linux
+type terminal struct{}
+
+func newTerminal() (*terminal, error) {
+	return nil, errors.New("job control is not supported on this system")
+}
+
+func (t *terminal) setForeground(pgid int) error { return nil }
+
+func (t *terminal) takeBack() {}
+
+func (t *terminal) setProcessGroup(cmd *exec.Cmd, pgid int, foreground bool) {}
+
+func (t *terminal) continueGroup(pgid int) error { return nil }
+
+func (t *terminal) hangUpGroup(pgid int) {}
+
+// Waits for the process started by cmd to exit. Returns its exit status.
+// Processes are never reported as stopped.
+func waitProcess(cmd *exec.Cmd) (int, bool, error) {
+	err := cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() > 0 {
+			return exitErr.ExitCode(), false, nil
+		}
+		return 1, false, nil
+	}
+	if err != nil {
+		return 1, false, err
+	}
+
+	return 0, false, nil
+}
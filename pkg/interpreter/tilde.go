@@ -0,0 +1,46 @@
+package interpreter
+
+import (
+	"os"
+	"os/user"
+)
+
+// Returns the directory the tilde prefix ~name stands for: the home
+// directory of the user name, or of the current user without name, $PWD
+// for + and $OLDPWD for -. False if there is none eg for an unknown user.
+func (i *Interpreter) tildeDir(name string) (string, bool) {
+	switch name {
+	case "":
+		if home, ok := i.env.Get("HOME"); ok {
+			return home, true
+		}
+		home, err := os.UserHomeDir()
+		return home, err == nil
+
+	case "+":
+		return i.env.Get("PWD")
+
+	case "-":
+		return i.env.Get("OLDPWD")
+	}
+
+	account, err := user.Lookup(name)
+	if err != nil {
+		return "", false
+	}
+	return account.HomeDir, true
+}
+
+// Returns the index after the tilde prefix starting at start eg ~user in
+// ~user/bin. It ends at a / or, in assignments, a :. -1 if part of it is
+// quoted or expanded, then the tilde is not expanded.
+func tildeEnd(raw []rune, start int, assignment bool) int {
+	n := start + 1
+	for ; n < len(raw) && raw[n] != '/' && !(assignment && raw[n] == ':'); n++ {
+		switch raw[n] {
+		case '\\', '\'', '"', '$', '`':
+			return -1
+		}
+	}
+	return n
+}
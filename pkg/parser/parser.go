@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"slices"
+
 	"github.com/ivf8/simp-shell/pkg/ast"
 	"github.com/ivf8/simp-shell/pkg/token"
 )
@@ -33,8 +35,13 @@ func (p *Parser) Parse() []ast.Cmd {
 func (p *Parser) command() ast.Cmd {
 	cmd := p.logical()
 
-	// Consume the semicolon
-	if p.match(token.SEMICOLON) {
+	// Run in the background if followed by &
+	if p.match(token.AMPERSAND) && cmd != nil {
+		return ast.NewBackgroundCmd(cmd)
+	}
+
+	// Consume the semicolon or newline
+	if p.match(token.SEMICOLON, token.NEWLINE) {
 	}
 
 	return cmd
@@ -45,7 +52,7 @@ func (p *Parser) command() ast.Cmd {
 // Returns a new logical command if && or || are found,
 // else it just returns the primary command
 func (p *Parser) logical() ast.Cmd {
-	cmd := p.primary()
+	cmd := p.pipeline()
 
 	// Advance to prevent infnite recursion
 	if cmd == nil {
@@ -57,6 +64,7 @@ func (p *Parser) logical() ast.Cmd {
 
 	if p.match(token.AND, token.OR) {
 		operator = p.previous()
+		p.linebreak()
 		right = p.logical()
 	}
 
@@ -67,19 +75,270 @@ func (p *Parser) logical() ast.Cmd {
 	}
 }
 
-// Parses individual command and its arguments
-// Returns a new PrimaryCmd.
+// Parses commands delimited by |.
+// Returns a new PipelineCmd if | is found, else it just returns the primary command
+func (p *Parser) pipeline() ast.Cmd {
+	cmd := p.primary()
+	if cmd == nil {
+		return nil
+	}
+
+	cmds := []ast.Cmd{cmd}
+	for p.match(token.PIPE) {
+		p.linebreak()
+		next := p.primary()
+		if next == nil {
+			break
+		}
+		cmds = append(cmds, next)
+	}
+
+	if len(cmds) == 1 {
+		return cmd
+	}
+	return ast.NewPipelineCmd(cmds)
+}
+
+// Parses individual command, its assignments, arguments and redirections.
+// Redirections can come anywhere in the command eg >out ls -a 2>&1
+// Returns a new PrimaryCmd, or an ArithmeticCmd for ((expr)), a
+// compound command eg IfCmd if it starts with a reserved word and a
+// FunctionCmd for the definition of a function.
 func (p *Parser) primary() ast.Cmd {
-	if p.match(token.PROG_NAME) {
-		programName := p.previous()
-		arguments := []token.Token{}
+	if p.match(token.ARITHMETIC_CMD) {
+		return ast.NewArithmeticCmd(p.previous())
+	}
+	if p.match(token.IF) {
+		return p.ifCmd()
+	}
+	if p.match(token.WHILE, token.UNTIL) {
+		return p.whileCmd()
+	}
+	if p.match(token.FOR) {
+		return p.forCmd()
+	}
+	if p.match(token.CASE) {
+		return p.caseCmd()
+	}
+	if p.match(token.LBRACE) {
+		return p.groupCmd()
+	}
+	if p.match(token.FUNCTION) || p.check(token.FUNCTION_NAME) {
+		return p.functionCmd()
+	}
+
+	var programName token.Token
+	var redirections []ast.Redirection
+	var assignments []token.Token
+	arguments := []token.Token{}
 
-		for p.match(token.ARG) {
+	for !p.isAtEnd() {
+		if p.match(token.REDIRECTIONS...) {
+			operator := p.previous()
+			if !p.match(token.ARG) {
+				break
+			}
+			redirections = append(redirections, ast.Redirection{
+				Operator: operator,
+				Target:   p.previous(),
+			})
+		} else if programName.Type == "" && p.match(token.ASSIGNMENT_WORD) {
+			assignments = append(assignments, p.previous())
+		} else if programName.Type == "" && p.match(token.PROG_NAME) {
+			programName = p.previous()
+		} else if programName.Type != "" && p.match(token.ARG) {
 			arguments = append(arguments, p.previous())
+		} else {
+			break
 		}
-		return ast.NewPrimaryCmd(programName, arguments)
 	}
-	return nil
+
+	if programName.Type == "" && len(redirections) == 0 && len(assignments) == 0 {
+		return nil
+	}
+
+	cmd := ast.NewPrimaryCmd(programName, arguments)
+	cmd.Assignments = assignments
+	cmd.Redirections = redirections
+	return cmd
+}
+
+// Parses an if command after its if, up to its fi and the redirections
+// following it. The scanner checked that its reserved words are in order.
+func (p *Parser) ifCmd() ast.Cmd {
+	keyword := p.previous()
+	clauses := []ast.IfClause{}
+
+	for {
+		condition := p.compoundList(token.THEN)
+		p.match(token.THEN)
+		body := p.compoundList(token.ELIF, token.ELSE, token.FI)
+		clauses = append(clauses, ast.IfClause{Condition: condition, Body: body})
+
+		if !p.match(token.ELIF) {
+			break
+		}
+	}
+
+	var elseBody []ast.Cmd
+	if p.match(token.ELSE) {
+		elseBody = p.compoundList(token.FI)
+	}
+	p.match(token.FI)
+
+	cmd := ast.NewIfCmd(keyword, clauses, elseBody)
+	cmd.Redirections = p.redirections()
+	return cmd
+}
+
+// Parses a while or until loop after its first word, up to its done and
+// the redirections following it.
+func (p *Parser) whileCmd() ast.Cmd {
+	keyword := p.previous()
+
+	condition := p.compoundList(token.DO)
+	p.match(token.DO)
+	body := p.compoundList(token.DONE)
+	p.match(token.DONE)
+
+	cmd := ast.NewWhileCmd(keyword, condition, body)
+	cmd.Redirections = p.redirections()
+	return cmd
+}
+
+// Parses a for loop after its for, up to its done and the redirections
+// following it. It is an ArithmeticForCmd if ((expr)) follows for. The
+// scanner checked the header of the loop.
+func (p *Parser) forCmd() ast.Cmd {
+	keyword := p.previous()
+
+	var arithmetic, name token.Token
+	var words []token.Token
+
+	if p.match(token.ARITHMETIC_CMD) {
+		arithmetic = p.previous()
+	} else {
+		name = p.advance()
+		if p.match(token.IN) {
+			words = []token.Token{}
+			for p.match(token.ARG) {
+				words = append(words, p.previous())
+			}
+		}
+	}
+
+	for p.match(token.SEMICOLON, token.NEWLINE) {
+	}
+	p.match(token.DO)
+	body := p.compoundList(token.DONE)
+	p.match(token.DONE)
+
+	if arithmetic.Type != "" {
+		cmd := ast.NewArithmeticForCmd(keyword, arithmetic, body)
+		cmd.Redirections = p.redirections()
+		return cmd
+	}
+
+	cmd := ast.NewForCmd(keyword, name, words, body)
+	cmd.Redirections = p.redirections()
+	return cmd
+}
+
+// Parses a case command after its case, up to its esac and the
+// redirections following it. Each clause is its patterns separated by |
+// and the commands up to the ;; ;& or ;;& ending it, if any.
+func (p *Parser) caseCmd() ast.Cmd {
+	keyword := p.previous()
+	word := p.advance()
+	p.match(token.IN)
+
+	clauses := []ast.CaseClause{}
+	for {
+		p.linebreak()
+		if !p.check(token.PATTERN) {
+			break
+		}
+
+		patterns := []token.Token{}
+		for p.match(token.PATTERN) {
+			patterns = append(patterns, p.previous())
+			p.match(token.PIPE)
+		}
+
+		clause := ast.CaseClause{Patterns: patterns}
+		clause.Body = p.compoundList(token.DSEMI, token.SEMIAND, token.DSEMIAND, token.ESAC)
+		if p.match(token.DSEMI, token.SEMIAND, token.DSEMIAND) {
+			clause.Terminator = p.previous()
+		}
+		clauses = append(clauses, clause)
+	}
+	p.match(token.ESAC)
+
+	cmd := ast.NewCaseCmd(keyword, word, clauses)
+	cmd.Redirections = p.redirections()
+	return cmd
+}
+
+// Parses commands grouped with { after the {, up to the } and the
+// redirections following it.
+func (p *Parser) groupCmd() ast.Cmd {
+	keyword := p.previous()
+
+	body := p.compoundList(token.RBRACE)
+	p.match(token.RBRACE)
+
+	cmd := ast.NewGroupCmd(keyword, body)
+	cmd.Redirections = p.redirections()
+	return cmd
+}
+
+// Parses the definition of a function from its name, after function if
+// given. The scanner checked that a compound command follows the name.
+func (p *Parser) functionCmd() ast.Cmd {
+	name := p.advance()
+	p.linebreak()
+
+	return ast.NewFunctionCmd(name, p.primary())
+}
+
+// Parses the commands of a compound command up to one of the reserved
+// words terminators eg the condition of an if up to then.
+func (p *Parser) compoundList(terminators ...token.TokenType) []ast.Cmd {
+	cmds := []ast.Cmd{}
+
+	for !p.isAtEnd() && !p.check(terminators...) {
+		cmd := p.command()
+		if cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	return cmds
+}
+
+// Parses the redirections following a compound command eg fi > out
+func (p *Parser) redirections() []ast.Redirection {
+	var redirections []ast.Redirection
+
+	for p.match(token.REDIRECTIONS...) {
+		operator := p.previous()
+		if !p.match(token.ARG) {
+			break
+		}
+		redirections = append(redirections, ast.Redirection{
+			Operator: operator,
+			Target:   p.previous(),
+		})
+	}
+
+	return redirections
+}
+
+// Skips the newlines that can follow an operator eg && at the end of a
+// line continues the command on the next one.
+func (p *Parser) linebreak() {
+	for p.match(token.NEWLINE) {
+	}
 }
 
 // Checks if the current token matches either of the given tokenTypes.
@@ -100,6 +359,12 @@ func (p *Parser) match(tokenTypes ...token.TokenType) bool {
 	return false
 }
 
+// Checks if the current token matches either of the given tokenTypes
+// without advancing current.
+func (p *Parser) check(tokenTypes ...token.TokenType) bool {
+	return !p.isAtEnd() && slices.Contains(tokenTypes, p.peek().Type)
+}
+
 // Advances current and returns the next token to be parsed.
 func (p *Parser) advance() token.Token {
 	if !p.isAtEnd() {
@@ -116,6 +116,111 @@ func TestMultipleCommandsDelimitedBySemicolon(t *testing.T) {
 	}
 }
 
+func TestPipelineCommand(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.PROG_NAME, "ls"),
+		newToken(token.PIPE, "|"),
+		newToken(token.PROG_NAME, "grep"),
+		newToken(token.ARG, "x"),
+		newToken(token.PIPE, "|"),
+		newToken(token.PROG_NAME, "wc"),
+		newToken(token.OR, "||"),
+		newToken(token.PROG_NAME, "cd"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	expected := []ast.Cmd{
+		ast.NewLogicalCmd(
+			ast.NewPipelineCmd([]ast.Cmd{
+				ast.NewPrimaryCmd(tokens[0], []token.Token{}),
+				ast.NewPrimaryCmd(tokens[2], []token.Token{tokens[3]}),
+				ast.NewPrimaryCmd(tokens[5], []token.Token{}),
+			}),
+			tokens[6],
+			ast.NewPrimaryCmd(tokens[7], []token.Token{}),
+		),
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %s. Expected %s",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
+func TestRedirections(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.GREAT, ">"),
+		newToken(token.ARG, "out"),
+		newToken(token.PROG_NAME, "ls"),
+		newToken(token.ARG, "-a"),
+		newToken(token.GREATAND, "2>&"),
+		newToken(token.ARG, "1"),
+		newToken(token.ARG, "-l"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.LESS, "<"),
+		newToken(token.ARG, "in"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	ls := ast.NewPrimaryCmd(tokens[2], []token.Token{tokens[3], tokens[6]})
+	ls.Redirections = []ast.Redirection{
+		{Operator: tokens[0], Target: tokens[1]},
+		{Operator: tokens[4], Target: tokens[5]},
+	}
+
+	redirectionOnly := ast.NewPrimaryCmd(token.Token{}, []token.Token{})
+	redirectionOnly.Redirections = []ast.Redirection{
+		{Operator: tokens[8], Target: tokens[9]},
+	}
+
+	expected := []ast.Cmd{ls, redirectionOnly}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %s. Expected %s",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
+func TestBackgroundCommand(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.PROG_NAME, "sleep"),
+		newToken(token.ARG, "1"),
+		newToken(token.AND, "&&"),
+		newToken(token.PROG_NAME, "ls"),
+		newToken(token.AMPERSAND, "&"),
+		newToken(token.PROG_NAME, "cd"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	expected := []ast.Cmd{
+		ast.NewBackgroundCmd(
+			ast.NewLogicalCmd(
+				ast.NewPrimaryCmd(tokens[0], []token.Token{tokens[1]}),
+				tokens[2],
+				ast.NewPrimaryCmd(tokens[3], []token.Token{}),
+			),
+		),
+		ast.NewPrimaryCmd(tokens[5], []token.Token{}),
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %s. Expected %s",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
 func TestNoProgramNameInTokens(t *testing.T) {
 	tokens := []token.Token{
 		newToken(token.ARG, "-a"),
@@ -151,6 +256,330 @@ func TestProgramNameAfterArgumentsInTokens(t *testing.T) {
 	}
 }
 
+func TestNewlinesSeparateCommands(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.PROG_NAME, "cd"),
+		newToken(token.AND, "&&"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.PROG_NAME, "ls"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.PROG_NAME, "clear"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	expected := []ast.Cmd{
+		ast.NewLogicalCmd(
+			ast.NewPrimaryCmd(tokens[1], []token.Token{}),
+			tokens[2],
+			ast.NewPrimaryCmd(tokens[5], []token.Token{}),
+		),
+		ast.NewPrimaryCmd(tokens[8], []token.Token{}),
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %s. Expected %s",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
+func TestAssignmentsBeforeProgramName(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.ASSIGNMENT_WORD, "FOO=1"),
+		newToken(token.PROG_NAME, "make"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.ASSIGNMENT_WORD, "BAR=2"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	withProgram := ast.NewPrimaryCmd(tokens[1], []token.Token{})
+	withProgram.Assignments = []token.Token{tokens[0]}
+	assignmentsOnly := ast.NewPrimaryCmd(token.Token{}, []token.Token{})
+	assignmentsOnly.Assignments = []token.Token{tokens[3]}
+
+	expected := []ast.Cmd{withProgram, assignmentsOnly}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %v. Expected %v",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
+func TestArithmeticCommand(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.ARITHMETIC_CMD, "i++"),
+		newToken(token.PIPE, "|"),
+		newToken(token.PROG_NAME, "cat"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	expected := []ast.Cmd{ast.NewPipelineCmd([]ast.Cmd{
+		ast.NewArithmeticCmd(tokens[0]),
+		ast.NewPrimaryCmd(tokens[2], []token.Token{}),
+	})}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %v. Expected %v",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
+func TestIfCommand(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.IF, "if"),
+		newToken(token.PROG_NAME, "a"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.THEN, "then"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.PROG_NAME, "b"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.ELIF, "elif"),
+		newToken(token.PROG_NAME, "c"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.THEN, "then"),
+		newToken(token.PROG_NAME, "d"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.ELSE, "else"),
+		newToken(token.PROG_NAME, "e"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.FI, "fi"),
+		newToken(token.GREAT, ">"),
+		newToken(token.ARG, "out"),
+		newToken(token.AND, "&&"),
+		newToken(token.PROG_NAME, "f"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	primary := func(n int) ast.Cmd {
+		return ast.NewPrimaryCmd(tokens[n], []token.Token{})
+	}
+
+	ifCmd := ast.NewIfCmd(tokens[0], []ast.IfClause{
+		{Condition: []ast.Cmd{primary(1)}, Body: []ast.Cmd{primary(5)}},
+		{Condition: []ast.Cmd{primary(8)}, Body: []ast.Cmd{primary(11)}},
+	}, []ast.Cmd{primary(14)})
+	ifCmd.Redirections = []ast.Redirection{{Operator: tokens[17], Target: tokens[18]}}
+
+	expected := []ast.Cmd{ast.NewLogicalCmd(ifCmd, tokens[19], primary(20))}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %v. Expected %v",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
+func TestWhileCommand(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.UNTIL, "until"),
+		newToken(token.PROG_NAME, "a"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.DO, "do"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.WHILE, "while"),
+		newToken(token.PROG_NAME, "b"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.DO, "do"),
+		newToken(token.PROG_NAME, "c"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.DONE, "done"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.DONE, "done"),
+		newToken(token.LESS, "<"),
+		newToken(token.ARG, "in"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	primary := func(n int) ast.Cmd {
+		return ast.NewPrimaryCmd(tokens[n], []token.Token{})
+	}
+
+	inner := ast.NewWhileCmd(tokens[5], []ast.Cmd{primary(6)}, []ast.Cmd{primary(9)})
+	outer := ast.NewWhileCmd(tokens[0], []ast.Cmd{primary(1)}, []ast.Cmd{inner})
+	outer.Redirections = []ast.Redirection{{Operator: tokens[14], Target: tokens[15]}}
+
+	expected := []ast.Cmd{outer}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %v. Expected %v",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
+func TestForCommand(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.FOR, "for"),
+		newToken(token.ARG, "x"),
+		newToken(token.IN, "in"),
+		newToken(token.ARG, "a"),
+		newToken(token.ARG, "b"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.DO, "do"),
+		newToken(token.FOR, "for"),
+		newToken(token.ARG, "y"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.DO, "do"),
+		newToken(token.PROG_NAME, "c"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.DONE, "done"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.DONE, "done"),
+		newToken(token.GREAT, ">"),
+		newToken(token.ARG, "out"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.FOR, "for"),
+		newToken(token.ARITHMETIC_CMD, ";;"),
+		newToken(token.DO, "do"),
+		newToken(token.PROG_NAME, "d"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.DONE, "done"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	primary := func(n int) ast.Cmd {
+		return ast.NewPrimaryCmd(tokens[n], []token.Token{})
+	}
+
+	inner := ast.NewForCmd(tokens[7], tokens[8], nil, []ast.Cmd{primary(11)})
+	outer := ast.NewForCmd(tokens[0], tokens[1], []token.Token{tokens[3], tokens[4]}, []ast.Cmd{inner})
+	outer.Redirections = []ast.Redirection{{Operator: tokens[16], Target: tokens[17]}}
+
+	expected := []ast.Cmd{
+		outer,
+		ast.NewArithmeticForCmd(tokens[19], tokens[20], []ast.Cmd{primary(22)}),
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %v. Expected %v",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
+func TestCaseCommand(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.CASE, "case"),
+		newToken(token.ARG, "x"),
+		newToken(token.IN, "in"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.PATTERN, "a"),
+		newToken(token.PIPE, "|"),
+		newToken(token.PATTERN, "b"),
+		newToken(token.PROG_NAME, "c"),
+		newToken(token.PIPE, "|"),
+		newToken(token.PROG_NAME, "d"),
+		newToken(token.SEMIAND, ";&"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.PATTERN, "e"),
+		newToken(token.DSEMIAND, ";;&"),
+		newToken(token.PATTERN, "*"),
+		newToken(token.PROG_NAME, "f"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.ESAC, "esac"),
+		newToken(token.GREAT, ">"),
+		newToken(token.ARG, "out"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	primary := func(n int) ast.Cmd {
+		return ast.NewPrimaryCmd(tokens[n], []token.Token{})
+	}
+
+	caseCmd := ast.NewCaseCmd(tokens[0], tokens[1], []ast.CaseClause{
+		{
+			Patterns:   []token.Token{tokens[4], tokens[6]},
+			Body:       []ast.Cmd{ast.NewPipelineCmd([]ast.Cmd{primary(7), primary(9)})},
+			Terminator: tokens[10],
+		},
+		{Patterns: []token.Token{tokens[12]}, Body: []ast.Cmd{}, Terminator: tokens[13]},
+		{Patterns: []token.Token{tokens[14]}, Body: []ast.Cmd{primary(15)}},
+	})
+	caseCmd.Redirections = []ast.Redirection{{Operator: tokens[18], Target: tokens[19]}}
+
+	expected := []ast.Cmd{caseCmd}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %v. Expected %v",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
+func TestFunctionCommand(t *testing.T) {
+	tokens := []token.Token{
+		newToken(token.FUNCTION_NAME, "f"),
+		newToken(token.LBRACE, "{"),
+		newToken(token.PROG_NAME, "a"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.RBRACE, "}"),
+		newToken(token.GREAT, ">"),
+		newToken(token.ARG, "out"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.FUNCTION, "function"),
+		newToken(token.FUNCTION_NAME, "g"),
+		newToken(token.NEWLINE, "\n"),
+		newToken(token.IF, "if"),
+		newToken(token.PROG_NAME, "b"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.THEN, "then"),
+		newToken(token.PROG_NAME, "c"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.FI, "fi"),
+		newToken(token.EOF, ""),
+	}
+
+	_parser := parser.NewParser(tokens)
+	result := _parser.Parse()
+
+	primary := func(n int) ast.Cmd {
+		return ast.NewPrimaryCmd(tokens[n], []token.Token{})
+	}
+
+	group := ast.NewGroupCmd(tokens[1], []ast.Cmd{primary(2)})
+	group.Redirections = []ast.Redirection{{Operator: tokens[5], Target: tokens[6]}}
+
+	expected := []ast.Cmd{
+		ast.NewFunctionCmd(tokens[0], group),
+		ast.NewFunctionCmd(tokens[9], ast.NewIfCmd(tokens[11],
+			[]ast.IfClause{{Condition: []ast.Cmd{primary(12)}, Body: []ast.Cmd{primary(15)}}}, nil,
+		)),
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Parse(%v) got %v. Expected %v",
+			tokens, cmdListToString(result), cmdListToString(expected),
+		)
+	}
+}
+
 func newToken(tokenType token.TokenType, lexeme string) token.Token {
 	return token.Token{
 		Type:   tokenType,
@@ -0,0 +1,183 @@
+package pattern
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Options changing which files a pattern matches
+type GlobOptions struct {
+	DotGlob  bool   // Names starting with . are matched without a leading . in the pattern
+	GlobStar bool   // ** matches any number of directories
+	Dir      string // Directory relative patterns are matched from, the current one if empty
+}
+
+// Returns path as found from options.Dir
+func (o GlobOptions) resolve(path string) string {
+	if path == "" {
+		path = "."
+	}
+	if o.Dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(o.Dir, path)
+}
+
+// Returns the sorted paths matching the pattern source eg src/*.go.
+// Each component of the path between slashes is matched against the
+// names in a directory. Names starting with . only match a pattern
+// starting with . unless options.DotGlob is set.
+func Glob(source string, options GlobOptions) []string {
+	components := splitPath(source)
+
+	prefixes := []string{""}
+	if strings.HasPrefix(source, "/") {
+		prefixes = []string{"/"}
+		components = components[1:]
+	}
+
+	for n, component := range components {
+		last := n == len(components)-1
+		next := []string{}
+
+		for _, prefix := range prefixes {
+			switch {
+			// Trailing / only keeps directories, others are repeated
+			case component == "":
+				if !last {
+					next = append(next, prefix)
+				} else if prefix != "" && isDir(options.resolve(prefix)) {
+					next = append(next, prefix)
+				}
+
+			case component == "**" && options.GlobStar:
+				next = append(next, globStar(prefix, last, options)...)
+
+			case !HasMeta(component):
+				path := prefix + Unescape(component)
+				if _, err := os.Lstat(options.resolve(path)); err == nil && (last || isDir(options.resolve(path))) {
+					next = append(next, joinPath(path, last))
+				}
+
+			default:
+				next = append(next, globDir(prefix, Compile(component), last, options)...)
+			}
+		}
+
+		prefixes = next
+	}
+
+	matches := []string{}
+	for _, prefix := range prefixes {
+		if prefix != "" && prefix != "/" {
+			matches = append(matches, prefix)
+		}
+	}
+	sort.Strings(matches)
+
+	return matches
+}
+
+// Returns the names in the directory prefix matching p, prefixed with
+// it. Unless last, only directories are kept and a / is appended.
+func globDir(prefix string, p *Pattern, last bool, options GlobOptions) []string {
+	entries, err := os.ReadDir(options.resolve(prefix))
+	if err != nil {
+		return nil
+	}
+
+	explicitDot := strings.HasPrefix(Unescape(p.String()), ".")
+
+	matches := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !explicitDot && !options.DotGlob {
+			continue
+		}
+		if !p.Match(name) {
+			continue
+		}
+
+		path := prefix + name
+		if !last && !isDir(options.resolve(path)) {
+			continue
+		}
+		matches = append(matches, joinPath(path, last))
+	}
+
+	return matches
+}
+
+// Returns prefix and every directory under it for **. As the last
+// component it also gives the files, without a trailing /.
+func globStar(prefix string, last bool, options GlobOptions) []string {
+	matches := []string{}
+	if !last || prefix != "" {
+		matches = append(matches, prefix)
+	}
+
+	return append(matches, walk(prefix, last, options)...)
+}
+
+// Returns the directories under prefix, and the files if last.
+func walk(prefix string, last bool, options GlobOptions) []string {
+	entries, err := os.ReadDir(options.resolve(prefix))
+	if err != nil {
+		return nil
+	}
+
+	matches := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !options.DotGlob {
+			continue
+		}
+
+		path := prefix + name
+
+		// Symbolic links to directories are not followed
+		if entry.IsDir() {
+			matches = append(matches, joinPath(path, last))
+			matches = append(matches, walk(path+"/", last, options)...)
+		} else if last {
+			matches = append(matches, path)
+		}
+	}
+
+	return matches
+}
+
+// Splits source at the slashes that separate its path components
+func splitPath(source string) []string {
+	components := []string{}
+	runes := []rune(source)
+
+	start := 0
+	for n := 0; n < len(runes); n++ {
+		switch runes[n] {
+		case '\\':
+			n++
+		case '/':
+			components = append(components, string(runes[start:n]))
+			start = n + 1
+		}
+	}
+
+	return append(components, string(runes[start:]))
+}
+
+// Returns path as the prefix of the next component unless it is the last one
+func joinPath(path string, last bool) string {
+	if last {
+		return path
+	}
+	return path + "/"
+}
+
+// Checks if path is a directory, following symbolic links
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
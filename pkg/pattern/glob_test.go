@@ -0,0 +1,70 @@
+package pattern_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ivf8/simp-shell/pkg/pattern"
+)
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", ".hidden.go", "src/c.go", "src/lib/d.go", "src/lib/e.txt", "*.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := pattern.Escape(filepath.ToSlash(dir)) + "/"
+	paths := func(names ...string) []string {
+		list := []string{}
+		for _, name := range names {
+			list = append(list, filepath.ToSlash(dir)+"/"+name)
+		}
+		return list
+	}
+
+	tests := []struct {
+		pattern  string
+		options  pattern.GlobOptions
+		expected []string
+	}{
+		{"*.go", pattern.GlobOptions{}, paths("*.go", "a.go", "b.go")},
+		{"\\*.go", pattern.GlobOptions{}, paths("*.go")},
+		{"[ab].go", pattern.GlobOptions{}, paths("a.go", "b.go")},
+		{".*", pattern.GlobOptions{}, paths(".hidden.go")},
+		{"*.go", pattern.GlobOptions{DotGlob: true}, paths("*.go", ".hidden.go", "a.go", "b.go")},
+		{"*/", pattern.GlobOptions{}, paths("src/")},
+		{"*/*/*", pattern.GlobOptions{}, paths("src/lib/d.go", "src/lib/e.txt")},
+		{"**/*.go", pattern.GlobOptions{}, paths("src/c.go")},
+		{"**/*.go", pattern.GlobOptions{GlobStar: true},
+			paths("*.go", "a.go", "b.go", "src/c.go", "src/lib/d.go")},
+		{"src/**", pattern.GlobOptions{GlobStar: true},
+			paths("src/", "src/c.go", "src/lib", "src/lib/d.go", "src/lib/e.txt")},
+		{"**/", pattern.GlobOptions{GlobStar: true}, paths("", "src/", "src/lib/")},
+		{"*.rs", pattern.GlobOptions{}, []string{}},
+		{"nope/*", pattern.GlobOptions{}, []string{}},
+	}
+
+	for _, test := range tests {
+		result := pattern.Glob(root+test.pattern, test.options)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Glob(%q, %+v) got %v. Expected %v", test.pattern, test.options,
+				strings.Join(result, " "), strings.Join(test.expected, " "))
+		}
+	}
+
+	// Relative patterns are matched from Dir and stay relative
+	result := pattern.Glob("src/*/*.go", pattern.GlobOptions{Dir: dir})
+	if expected := []string{"src/lib/d.go"}; !reflect.DeepEqual(result, expected) {
+		t.Errorf("Glob(%q) from %s got %v. Expected %v", "src/*/*.go", dir, result, expected)
+	}
+}
@@ -0,0 +1,218 @@
+package pattern
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Characters with a special meaning in patterns
+const SPECIAL_CHARS = `*?[\`
+
+// Names of the character classes that can be used in brackets eg [[:digit:]]
+var (
+	CHARACTER_CLASSES = []string{
+		"alnum", "alpha", "ascii", "blank", "cntrl", "digit", "graph",
+		"lower", "print", "punct", "space", "upper", "word", "xdigit",
+	}
+	CHARACTER_CLASSES_MAP = SliceToMap(CHARACTER_CLASSES)
+)
+
+// A shell pattern eg *.go or file[0-9]?. * matches any string, ? any
+// character and [...] any of the characters listed. A back slash makes
+// the character after it match itself.
+type Pattern struct {
+	source string
+	regexp *regexp.Regexp
+}
+
+// Compiles the pattern source. A pattern that is not valid eg [z-a]
+// only matches itself.
+func Compile(source string) *Pattern {
+	re, err := regexp.Compile("^(?s:" + toRegexp(source) + ")$")
+	if err != nil {
+		re = regexp.MustCompile("^" + regexp.QuoteMeta(Unescape(source)) + "$")
+	}
+
+	return &Pattern{
+		source: source,
+		regexp: re,
+	}
+}
+
+// Checks if the whole of s matches the pattern
+func (p *Pattern) Match(s string) bool {
+	return p.regexp.MatchString(s)
+}
+
+// Returns the source of the pattern
+func (p *Pattern) String() string {
+	return p.source
+}
+
+// Checks if s matches the pattern source
+func Match(source, s string) bool {
+	return Compile(source).Match(s)
+}
+
+// Checks if source has characters that match other strings than
+// themselves eg *. Source without them can be compared as is once unescaped.
+func HasMeta(source string) bool {
+	runes := []rune(source)
+	for n := 0; n < len(runes); n++ {
+		switch runes[n] {
+		case '\\':
+			n++
+		case '*', '?':
+			return true
+		case '[':
+			if _, end := bracket(runes, n); end > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns s with the special characters escaped so that it only matches
+// itself
+func Escape(s string) string {
+	escaped := strings.Builder{}
+	for _, c := range s {
+		if strings.ContainsRune(SPECIAL_CHARS+"]", c) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(c)
+	}
+	return escaped.String()
+}
+
+// Returns source with the escaping back slashes removed
+func Unescape(source string) string {
+	unescaped := strings.Builder{}
+	runes := []rune(source)
+	for n := 0; n < len(runes); n++ {
+		if runes[n] == '\\' && n+1 < len(runes) {
+			n++
+		}
+		unescaped.WriteRune(runes[n])
+	}
+	return unescaped.String()
+}
+
+// Translates a pattern to a regular expression
+func toRegexp(source string) string {
+	re := strings.Builder{}
+	runes := []rune(source)
+
+	for n := 0; n < len(runes); n++ {
+		c := runes[n]
+		switch c {
+		case '*':
+			re.WriteString(".*")
+
+		case '?':
+			re.WriteString(".")
+
+		case '\\':
+			if n+1 < len(runes) {
+				n++
+			}
+			re.WriteString(regexp.QuoteMeta(string(runes[n])))
+
+		case '[':
+			class, end := bracket(runes, n)
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			re.WriteString(class)
+			n = end
+
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return re.String()
+}
+
+// Translates the bracket expression starting at runes[start] eg [!a-z] to
+// a regular expression character class. Returns the class and the index of
+// the closing ], or -1 if the bracket is not closed.
+func bracket(runes []rune, start int) (string, int) {
+	class := strings.Builder{}
+	class.WriteRune('[')
+
+	n := start + 1
+	if n < len(runes) && (runes[n] == '!' || runes[n] == '^') {
+		class.WriteRune('^')
+		n++
+	}
+
+	// A ] first in the list is one of the characters matched
+	first := n
+	for ; n < len(runes); n++ {
+		c := runes[n]
+		switch {
+		case c == ']' && n > first:
+			class.WriteRune(']')
+			return class.String(), n
+
+		// Character classes eg [:alpha:]
+		case c == '[' && n+1 < len(runes) && runes[n+1] == ':':
+			end := classEnd(runes, n+2)
+			if end < 0 || !CHARACTER_CLASSES_MAP[string(runes[n+2:end])] {
+				class.WriteString(`\[`)
+				continue
+			}
+			class.WriteString(string(runes[n : end+2]))
+			n = end + 1
+
+		case c == '\\' && n+1 < len(runes):
+			n++
+			if runes[n] == '-' {
+				class.WriteString(`\-`)
+				continue
+			}
+			class.WriteString(classChar(runes[n]))
+
+		default:
+			class.WriteString(classChar(c))
+		}
+	}
+
+	return "", -1
+}
+
+// Returns the index of the :] ending a character class name starting at
+// start, -1 if there is none.
+func classEnd(runes []rune, start int) int {
+	for n := start; n+1 < len(runes); n++ {
+		if runes[n] == ':' && runes[n+1] == ']' {
+			return n
+		}
+	}
+	return -1
+}
+
+// Returns c escaped for use in a regular expression character class
+func classChar(c rune) string {
+	if c < 128 && !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' {
+		return `\` + string(c)
+	}
+	return string(c)
+}
+
+// Creates a map from a slice
+// The created map uses the slice values as keys and sets the value of each key to true.
+// The produced map can be used to check if a certain value is found in the parent slice.
+func SliceToMap[T comparable](arr []T) map[T]bool {
+	m := make(map[T]bool)
+
+	for _, v := range arr {
+		m[v] = true
+	}
+
+	return m
+}
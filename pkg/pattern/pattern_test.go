@@ -0,0 +1,81 @@
+package pattern_test
+
+import (
+	"testing"
+
+	"github.com/ivf8/simp-shell/pkg/pattern"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		expected   bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "main.go.txt", false},
+		{"*", "", true},
+		{"a*b*c", "aXbYbc", true},
+		{"?", "é", true},
+		{"??", "a", false},
+		{"*", "dir/file\n", true},
+		{"file[0-9]", "file7", true},
+		{"file[!0-9]", "file7", false},
+		{"file[^0-9]", "filex", true},
+		{"[]a]", "]", true},
+		{"[a-]", "-", true},
+		{"[a\\-z]", "b", false},
+		{"[[:digit:]x]", "5", true},
+		{"[[:digit:]x]", "y", false},
+		{"[[:nope:]]", "n", false},
+		{"[abc", "[abc", true},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"a.b", "aXb", false},
+		{"(a|b)", "(a|b)", true},
+		{"[z-a]", "[z-a]", true},
+		{"[\\]]", "]", true},
+	}
+
+	for _, test := range tests {
+		if result := pattern.Match(test.pattern, test.s); result != test.expected {
+			t.Errorf("Match(%q, %q) got %v. Expected %v", test.pattern, test.s, result, test.expected)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []string{"*.go", "a?b", "[x]", `back\slash`, "plain"}
+
+	for _, test := range tests {
+		escaped := pattern.Escape(test)
+		if !pattern.Match(escaped, test) {
+			t.Errorf("Match(%q, %q) got false. Expected true", escaped, test)
+		}
+		if pattern.HasMeta(escaped) {
+			t.Errorf("HasMeta(%q) got true. Expected false", escaped)
+		}
+		if unescaped := pattern.Unescape(escaped); unescaped != test {
+			t.Errorf("Unescape(%q) got %q. Expected %q", escaped, unescaped, test)
+		}
+	}
+}
+
+func TestHasMeta(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected bool
+	}{
+		{"*.go", true},
+		{"file?", true},
+		{"[ab]", true},
+		{"[ab", false},
+		{"plain", false},
+		{"\\*", false},
+	}
+
+	for _, test := range tests {
+		if result := pattern.HasMeta(test.pattern); result != test.expected {
+			t.Errorf("HasMeta(%q) got %v. Expected %v", test.pattern, result, test.expected)
+		}
+	}
+}
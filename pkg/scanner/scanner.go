@@ -1,7 +1,10 @@
 package scanner
 
 import (
+	"io"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/ivf8/simp-shell/pkg/eiene_errors"
 	"github.com/ivf8/simp-shell/pkg/token"
@@ -14,10 +17,101 @@ var (
 	SPECIAL_CHARS_MAP = SliceToMap(SPECIAL_CHARS)
 )
 
+// < and > characters. They start a redirection unless quoted.
+var (
+	REDIRECTION_CHARS     = []rune{'<', '>'}
+	REDIRECTION_CHARS_MAP = SliceToMap(REDIRECTION_CHARS)
+)
+
+// Prompts shown when a command is continued after an operator.
+var OPERATOR_PROMPTS = map[token.TokenType]string{
+	token.AND:  "cmdand>",
+	token.OR:   "cmdor>",
+	token.PIPE: "pipe>",
+}
+
+// Words starting or continuing a compound command where a command starts
+var RESERVED_WORDS = map[string]token.TokenType{
+	"if":   token.IF,
+	"then": token.THEN,
+	"elif": token.ELIF,
+	"else": token.ELSE,
+	"fi":   token.FI,
+
+	"while": token.WHILE,
+	"until": token.UNTIL,
+	"do":    token.DO,
+	"done":  token.DONE,
+
+	"for": token.FOR,
+
+	"case": token.CASE,
+	"esac": token.ESAC,
+
+	"{": token.LBRACE,
+	"}": token.RBRACE,
+
+	"function": token.FUNCTION,
+}
+
+// Reserved words opening a compound command and the one closing it
+var BLOCK_ENDS = map[token.TokenType]token.TokenType{
+	token.IF:     token.FI,
+	token.WHILE:  token.DONE,
+	token.UNTIL:  token.DONE,
+	token.FOR:    token.DONE,
+	token.CASE:   token.ESAC,
+	token.LBRACE: token.RBRACE,
+}
+
+// Reserved words continuing a compound command and the ones they can
+// follow eg then comes after the condition following if or elif.
+var BLOCK_PARTS = map[token.TokenType][]token.TokenType{
+	token.THEN:   {token.IF, token.ELIF},
+	token.ELIF:   {token.THEN},
+	token.ELSE:   {token.THEN},
+	token.FI:     {token.THEN, token.ELSE},
+	token.IN:     {token.FOR, token.CASE},
+	token.DO:     {token.WHILE, token.UNTIL, token.FOR, token.IN},
+	token.DONE:   {token.DO},
+	token.ESAC:   CASE_PARTS,
+	token.RBRACE: {token.LBRACE},
+}
+
+// Parts of a case command after which esac can come: in before the
+// first pattern, the body of a clause after its patterns and the end of
+// a clause before the next patterns.
+var CASE_PARTS = []token.TokenType{token.IN, token.PATTERN, token.DSEMI, token.SEMIAND, token.DSEMIAND}
+
+// Tokens that can follow each one in the header of a for loop, from for
+// up to do eg for i in a b; or for ((i = 0; i < 3; i++))
+var FOR_HEADER = map[token.TokenType][]token.TokenType{
+	token.FOR:            {token.ARG, token.ARITHMETIC_CMD},
+	token.ARG:            {token.IN, token.ARG, token.SEMICOLON, token.NEWLINE},
+	token.IN:             {token.ARG, token.SEMICOLON, token.NEWLINE},
+	token.ARITHMETIC_CMD: {token.SEMICOLON, token.NEWLINE}, // Or do
+	token.SEMICOLON:      {token.NEWLINE},
+	token.NEWLINE:        {token.NEWLINE},
+}
+
+// Tokens that can follow each one in the header of a case command, from
+// case up to in eg case $x in
+var CASE_HEADER = map[token.TokenType][]token.TokenType{
+	token.CASE: {token.ARG},
+	token.ARG:  {token.IN},
+}
+
+// Compound command being scanned eg an if waiting for its fi.
+type block struct {
+	opening token.TokenType // Reserved word starting the block eg IF
+	part    token.TokenType // Last reserved word of the block eg THEN
+	empty   bool            // No command follows part yet
+	header  int             // Index of the token of for or case
+}
+
 type Flags struct {
-	slashFound bool
-	spaceFound bool
-	newCmd     bool // If true next token is the program name
+	newCmd            bool // If true next token is the program name
+	redirectionTarget bool // If true next token is the file redirected to
 }
 
 // Function for continuing to read a command from the cmd line.
@@ -29,7 +123,11 @@ type Scanner struct {
 	start   int           // Index to start indexing the current lexeme being scanned
 	current int           // Index of next character to be scanned
 
+	cursor    token.Position // Position of the character at cursorIdx
+	cursorIdx int
+
 	flags       *Flags
+	blocks      []block // Open compound commands, the innermost last
 	eieneErrors *eiene_errors.EieneErrors
 
 	reader ReaderFunc // Function for reading a command that is continued
@@ -41,12 +139,13 @@ func NewScanner(source string, e *eiene_errors.EieneErrors, reader ReaderFunc) *
 		Tokens:      []token.Token{},
 		start:       0,
 		current:     0,
+		cursor:      token.Position{Line: 1, Column: 1, Offset: 0},
+		cursorIdx:   0,
 		eieneErrors: e,
 
 		flags: &Flags{
-			slashFound: false,
-			spaceFound: false,
-			newCmd:     true,
+			newCmd:            true,
+			redirectionTarget: false,
 		},
 
 		reader: reader,
@@ -56,10 +155,24 @@ func NewScanner(source string, e *eiene_errors.EieneErrors, reader ReaderFunc) *
 // Scans for tokens in source.
 func (s *Scanner) ScanTokens() []token.Token {
 	s.eieneErrors.ResetErrors()
+	s.eieneErrors.SetSource(string(s.source))
 
 	for !s.isAtEnd() && !s.eieneErrors.HadError {
 		s.start = s.current
+		scanned := len(s.Tokens)
 		s.scanToken()
+
+		for n := scanned; n < len(s.Tokens) && !s.eieneErrors.HadError; n++ {
+			s.header(n)
+			s.functionBody(n)
+		}
+	}
+
+	if !s.eieneErrors.HadError && len(s.blocks) > 0 {
+		closing := BLOCK_ENDS[s.blocks[len(s.blocks)-1].opening]
+		s.eieneErrors.IncompleteInputError(reservedLexeme(closing))
+	} else if !s.eieneErrors.HadError && s.functionHeader() {
+		s.eieneErrors.IncompleteInputError("function body")
 	}
 
 	if s.eieneErrors.HadError {
@@ -69,19 +182,35 @@ func (s *Scanner) ScanTokens() []token.Token {
 	s.Tokens = append(s.Tokens, token.Token{
 		Type:   token.EOF,
 		Lexeme: "",
+		Pos:    s.position(len(s.source)),
 	})
 
 	return s.Tokens
 }
 
+// Returns the source scanned so far, including any continued lines.
+func (s Scanner) Source() string {
+	return string(s.source)
+}
+
 // Scan and append single tokens.
 func (s *Scanner) scanToken() {
 	c := s.advance()
 
+	// Only words, | and ) make up the patterns of a case command
+	if s.casePatterns() && (c == ';' || c == '&' || REDIRECTION_CHARS_MAP[c]) {
+		s.eieneErrors.ParseError(s.position(s.start), string(c))
+		return
+	}
+
 	switch c {
 	case ';':
+		if s.caseClause() && (s.peek() == ';' || s.peek() == '&') {
+			s.caseTerminator()
+			return
+		}
 		if SPECIAL_CHARS_MAP[s.peek()] {
-			s.eieneErrors.ParseError(";" + string(s.peek()))
+			s.eieneErrors.ParseError(s.position(s.start), ";"+string(s.peek()))
 			return
 		}
 		s.addToken(token.SEMICOLON)
@@ -90,27 +219,49 @@ func (s *Scanner) scanToken() {
 	case '&':
 		if s.peek() == '&' {
 			s.logicalOperator(token.AND)
+		} else if s.peek() == '>' {
+			s.redirection()
 		} else {
-			// TODO: Background task
-			s.eieneErrors.NotImplementedError("Background execution (&)")
+			s.background()
 		}
 	case '|':
-		if s.peek() == '|' {
+		if s.casePatterns() {
+			s.patternSeparator()
+		} else if s.peek() == '|' {
 			s.logicalOperator(token.OR)
 		} else {
-			// TODO: Piping
-			s.eieneErrors.NotImplementedError("Piping (|)")
+			s.controlOperator(token.PIPE)
 		}
 
+	case '<', '>':
+		s.redirection()
+
 	// Whitespace
 	case ' ',
 		'\t',
 		'\r':
-		s.flags.spaceFound = true
 		break
 
 	case '\n':
-		break
+		s.addToken(token.NEWLINE)
+		s.flags.newCmd = true
+
+	case '(':
+		newCmd := s.flags.newCmd && !s.flags.redirectionTarget
+		if s.casePatterns() && s.lastType() != token.PATTERN && s.lastType() != token.PIPE {
+			break // Optional ( before the patterns of a clause
+		} else if (newCmd || s.lastType() == token.FOR) && s.peek() == '(' {
+			s.arithmeticCommand()
+		} else {
+			s.word()
+		}
+
+	case ')':
+		if s.casePatterns() {
+			s.patternsEnd()
+		} else {
+			s.word()
+		}
 
 	// Comment
 	case '#':
@@ -118,72 +269,801 @@ func (s *Scanner) scanToken() {
 			s.advance()
 		}
 
-	// Back slash - Escape next character or continue reading command in next line
-	case '\\':
-		_continueReading := false
-		if s.isAtEnd() {
-			s.source = s.source[:len(s.source)-1]
-			_continueReading = true
-		} else {
-			s.source = append(s.source[:s.current-1], s.source[s.current:]...)
+	// Command and arguments
+	default:
+		s.word()
+	}
+}
+
+// Scans a word, the program name, one of its arguments or a file
+// redirected to. A word ends at unquoted whitespace, one of the
+// SPECIAL_CHARS or one of the REDIRECTION_CHARS.
+// Quotes and escaping backslashes are removed from the lexeme, keeping
+// the characters they protect.
+func (s *Scanner) word() {
+	s.current-- // Include the first character of the word
+
+	value := strings.Builder{}
+	quoted := false // Quoted words are added even when empty eg ''
+	patterns := s.casePatterns()
+	definition := s.lastType() == token.FUNCTION || s.commandStart()
+
+	for !s.isAtEnd() && !s.eieneErrors.HadError {
+		c := s.peek()
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || SPECIAL_CHARS_MAP[c] || REDIRECTION_CHARS_MAP[c] {
+			break
+		}
+		if patterns && c == ')' {
+			break
+		}
+		// The () after the name of a function defined eg f(){
+		if definition && c == '(' && s.peekNext() == ')' && !quoted && isFunctionName(value.String()) {
+			break
 		}
 
-		// Prompt for command continuation if the command ends with \
-		if s.peek() == rune(0) && _continueReading {
-			line, err := s.reader(">")
-			if err != nil {
-				s.eieneErrors.HadError = true
-				s.eieneErrors.Errors = append(s.eieneErrors.Errors, err.Error())
+		switch c {
+		// Back slash - Escape next character or continue reading command in next line
+		case '\\':
+			s.advance()
+			if s.isAtEnd() {
+				s.continueLine()
+				continue
 			}
-			if len(line) > 0 {
-				s.source = append(s.source, []rune(line)...)
-				s.current-- // Don't skip first character of next line, might be space
+			if s.peek() == '\n' {
+				s.advance()
+				continue
+			}
+			value.WriteRune(s.advance())
+			quoted = true
+
+		// Everything between single quotes is literal
+		case '\'':
+			s.singleQuoted(&value)
+			quoted = true
+
+		// Whitespace and special characters between double quotes are literal
+		case '"':
+			s.doubleQuoted(&value)
+			quoted = true
+
+		// Command substitutions and parameter expansions, kept as written
+		// until the command is run
+		case '$':
+			if s.peekNext() == '(' {
+				s.commandSubstitution(&value)
+			} else if s.peekNext() == '{' {
+				s.parameterExpansion(&value, false)
+			} else {
+				value.WriteRune(s.advance())
 			}
+
+		case '`':
+			s.backquoted(&value)
+
+		default:
+			value.WriteRune(s.advance())
+		}
+	}
+
+	if s.eieneErrors.HadError || (value.Len() == 0 && !quoted) {
+		return
+	}
+
+	if patterns {
+		s.pattern(value.String())
+		return
+	}
+
+	// Digits just before a redirection are the file descriptor redirected eg 2>
+	if !quoted && REDIRECTION_CHARS_MAP[s.peek()] && isNumber(value.String()) {
+		s.advance()
+		s.redirection()
+		return
+	}
+
+	raw := string(s.source[s.start:s.current])
+
+	// name() or function name starts the definition of a function
+	if s.lastType() == token.FUNCTION {
+		if quoted || !isFunctionName(raw) {
+			s.eieneErrors.ParseError(s.position(s.start), raw)
+			return
+		}
+		s.functionParens()
+		s.functionName(value.String(), raw)
+		return
+	}
+	if definition && !quoted && isFunctionName(raw) && s.functionParens() {
+		s.functionName(value.String(), raw)
+		return
+	}
+
+	if reserved, ok := RESERVED_WORDS[raw]; ok && s.commandStart() {
+		s.reservedWord(reserved, raw)
+		return
+	}
+
+	// in is only reserved after the name of a for loop or the word of a case
+	if raw == "in" && s.headerWord() {
+		s.reservedWord(token.IN, raw)
+		return
+	}
+
+	tokenType := token.ARG
+	if s.flags.newCmd && !s.flags.redirectionTarget {
+		tokenType = token.PROG_NAME
+		if isAssignment(raw) {
+			tokenType = token.ASSIGNMENT_WORD
+		}
+		s.command()
+	}
+
+	// Only redirections and operators follow a compound command eg fi > out
+	if tokenType == token.ARG && !s.flags.redirectionTarget && s.afterBlock() {
+		s.eieneErrors.ParseError(s.position(s.start), raw)
+		return
+	}
+
+	s.Tokens = append(s.Tokens, token.Token{
+		Type:   tokenType,
+		Lexeme: value.String(),
+		Raw:    raw,
+		Pos:    s.position(s.start),
+	})
+
+	// A file redirected to can come before the program name eg >out ls,
+	// so can assignments eg FOO=1 ls
+	if s.flags.redirectionTarget || tokenType == token.ASSIGNMENT_WORD {
+		s.flags.redirectionTarget = false
+		return
+	}
+
+	// Tokens after the program name will be arguments or operators
+	s.flags.newCmd = false
+}
+
+// Adds a reserved word and checks it comes in the right place of its
+// compound command eg then after the condition of an if. Reports an error
+// otherwise.
+func (s *Scanner) reservedWord(tokenType token.TokenType, lexeme string) {
+	pos := s.position(s.start)
+
+	if _, opening := BLOCK_ENDS[tokenType]; opening {
+		s.command()
+		s.blocks = append(s.blocks, block{opening: tokenType, part: tokenType, empty: true})
+	} else if tokenType == token.FUNCTION {
+		s.command()
+	} else {
+		// Other reserved words end the commands before them, they cannot
+		// follow an operator eg && then
+		last := len(s.blocks) - 1
+		if last < 0 || s.blocks[last].empty || !slices.Contains(BLOCK_PARTS[tokenType], s.blocks[last].part) {
+			s.eieneErrors.ParseError(pos, lexeme)
+			return
+		}
+		if previous := s.Tokens[len(s.Tokens)-1].Type; previous == token.AND || previous == token.OR || previous == token.PIPE {
+			s.eieneErrors.ParseError(pos, lexeme)
+			return
+		}
+		if closing(tokenType) && BLOCK_ENDS[s.blocks[last].opening] != tokenType {
+			s.eieneErrors.ParseError(pos, lexeme)
+			return
+		}
+
+		if BLOCK_ENDS[s.blocks[last].opening] == tokenType {
+			s.blocks = s.blocks[:last]
+			s.command()
+		} else {
+			// Clauses of a case can be empty, and so can the whole command
+			s.blocks[last].part = tokenType
+			s.blocks[last].empty = s.blocks[last].opening != token.CASE
+		}
+	}
+
+	// The header of a for loop or a case is checked by header
+	if tokenType == token.FOR || tokenType == token.CASE {
+		s.blocks[len(s.blocks)-1].header = len(s.Tokens)
+	}
+
+	s.Tokens = append(s.Tokens, token.Token{
+		Type:   tokenType,
+		Lexeme: lexeme,
+		Raw:    lexeme,
+		Pos:    pos,
+	})
+
+	// A command follows all but the words closing a block, and the
+	// words of the header of a for loop or a case are arguments
+	s.flags.newCmd = !s.afterBlock() && tokenType != token.FOR && tokenType != token.CASE &&
+		tokenType != token.IN && tokenType != token.FUNCTION
+}
+
+// Checks if a word at s.start would start a command, where reserved words
+// are recognised. Words after assignments or redirections do not eg if in
+// FOO=1 if.
+func (s *Scanner) commandStart() bool {
+	if !s.flags.newCmd || s.flags.redirectionTarget {
+		return false
+	}
+	if len(s.Tokens) == 0 {
+		return true
+	}
+
+	last := s.Tokens[len(s.Tokens)-1].Type
+	return last != token.ASSIGNMENT_WORD && last != token.ARG
+}
+
+// Checks the token at index n if it is in the header of the innermost
+// for loop or case, which must follow the order of FOR_HEADER or
+// CASE_HEADER. The arithmetic header must have three expressions. Marks
+// the loop as not empty so that do can follow the header.
+func (s *Scanner) header(n int) {
+	last := len(s.blocks) - 1
+	if last < 0 || n <= s.blocks[last].header {
+		return
+	}
+
+	table := FOR_HEADER
+	switch opening, part := s.blocks[last].opening, s.blocks[last].part; {
+	case opening == token.FOR && (part == token.FOR || part == token.IN):
+	case opening == token.CASE && part == token.CASE:
+		table = CASE_HEADER
+	default:
+		return
+	}
+
+	previous, current := s.Tokens[n-1], s.Tokens[n]
+
+	// Only one name follows for, the words come after in
+	valid := slices.Contains(table[previous.Type], current.Type)
+	if previous.Type == token.ARG && current.Type == token.ARG && s.blocks[last].part != token.IN {
+		valid = false
+	}
+	if current.Type == token.ARITHMETIC_CMD && strings.Count(current.Lexeme, ";") != 2 {
+		valid = false
+	}
+
+	if !valid {
+		near := current.Lexeme
+		switch current.Type {
+		case token.NEWLINE:
+			near = "newline"
+		case token.ARITHMETIC_CMD:
+			near = current.Raw
+		}
+		s.eieneErrors.ParseError(current.Pos, near)
+		return
+	}
+	s.blocks[last].empty = false
+}
+
+// Checks if the last token is the name following for or the word
+// following case
+func (s *Scanner) headerWord() bool {
+	n := len(s.Tokens)
+	if n < 2 || s.Tokens[n-1].Type != token.ARG {
+		return false
+	}
+	return s.Tokens[n-2].Type == token.FOR || s.Tokens[n-2].Type == token.CASE
+}
+
+// Checks if the innermost block is a case waiting for the patterns of a
+// clause, after in or the end of the previous clause.
+func (s *Scanner) casePatterns() bool {
+	last := len(s.blocks) - 1
+	return last >= 0 && s.blocks[last].opening == token.CASE &&
+		s.blocks[last].part != token.CASE && s.blocks[last].part != token.PATTERN
+}
+
+// Checks if the innermost block is a case in the body of a clause
+func (s *Scanner) caseClause() bool {
+	last := len(s.blocks) - 1
+	return last >= 0 && s.blocks[last].opening == token.CASE && s.blocks[last].part == token.PATTERN
+}
+
+// Adds a pattern of a case clause scanned by word, or the esac closing
+// the case in place of the first pattern. Patterns are separated by |.
+func (s *Scanner) pattern(lexeme string) {
+	raw := string(s.source[s.start:s.current])
+
+	if last := s.lastType(); last == token.PATTERN {
+		s.eieneErrors.ParseError(s.position(s.start), raw)
+		return
+	} else if last != token.PIPE && raw == "esac" {
+		s.reservedWord(token.ESAC, raw)
+		return
+	}
+
+	s.Tokens = append(s.Tokens, token.Token{
+		Type:   token.PATTERN,
+		Lexeme: lexeme,
+		Raw:    raw,
+		Pos:    s.position(s.start),
+	})
+}
+
+// Adds the | separating two patterns of a case clause
+func (s *Scanner) patternSeparator() {
+	if s.lastType() != token.PATTERN {
+		s.eieneErrors.ParseError(s.position(s.start), "|")
+		return
+	}
+	s.addToken(token.PIPE)
+}
+
+// Scans the ) ending the patterns of a case clause. The commands of its
+// body follow.
+func (s *Scanner) patternsEnd() {
+	if s.lastType() != token.PATTERN {
+		s.eieneErrors.ParseError(s.position(s.start), ")")
+		return
+	}
+
+	s.blocks[len(s.blocks)-1].part = token.PATTERN
+	s.flags.newCmd = true
+}
+
+// Adds the ;; ;& or ;;& ending the body of a case clause. s.current is
+// after the first ;
+func (s *Scanner) caseTerminator() {
+	tokenType := token.SEMIAND
+	if s.advance() == ';' {
+		tokenType = token.DSEMI
+		if s.peek() == '&' {
+			tokenType = token.DSEMIAND
+			s.advance()
+		}
+	}
+
+	// The commands before it cannot end with an operator eg && ;;
+	if previous := s.lastType(); previous == token.AND || previous == token.OR || previous == token.PIPE {
+		s.eieneErrors.ParseError(s.position(s.start), string(s.source[s.start:s.current]))
+		return
+	}
+
+	s.addToken(tokenType)
+	s.blocks[len(s.blocks)-1].part = tokenType
+}
+
+// Consumes the () following the name of a function defined, with blanks
+// before it eg f () {. Returns false if there is none.
+func (s *Scanner) functionParens() bool {
+	n := s.current
+	for n < len(s.source) && (s.source[n] == ' ' || s.source[n] == '\t') {
+		n++
+	}
+
+	if n+1 < len(s.source) && s.source[n] == '(' && s.source[n+1] == ')' {
+		s.current = n + 2
+		return true
+	}
+	return false
+}
+
+// Adds the name of a function defined. The compound command of its body
+// follows.
+func (s *Scanner) functionName(lexeme, raw string) {
+	s.command()
+	s.Tokens = append(s.Tokens, token.Token{
+		Type:   token.FUNCTION_NAME,
+		Lexeme: lexeme,
+		Raw:    raw,
+		Pos:    s.position(s.start),
+	})
+
+	s.flags.newCmd = true
+	s.flags.redirectionTarget = false
+}
+
+// Checks the token at index n if it follows function, which the name of
+// the function must follow, or the name of a function, which a compound
+// command must follow after any newlines.
+func (s *Scanner) functionBody(n int) {
+	current := s.Tokens[n]
+
+	if n > 0 && s.Tokens[n-1].Type == token.FUNCTION && current.Type != token.FUNCTION_NAME {
+		near := current.Lexeme
+		if current.Type == token.NEWLINE {
+			near = "newline"
+		}
+		s.eieneErrors.ParseError(current.Pos, near)
+		return
+	}
+
+	if current.Type == token.NEWLINE {
+		return
+	}
+
+	previous := n - 1
+	for previous >= 0 && s.Tokens[previous].Type == token.NEWLINE {
+		previous--
+	}
+	if previous < 0 || s.Tokens[previous].Type != token.FUNCTION_NAME {
+		return
+	}
+
+	if _, opening := BLOCK_ENDS[current.Type]; !opening {
+		s.eieneErrors.ParseError(current.Pos, current.Lexeme)
+	}
+}
+
+// Checks if the body of a function defined is still to come, after
+// function or the name of the function and any newlines.
+func (s *Scanner) functionHeader() bool {
+	n := len(s.Tokens) - 1
+	for n >= 0 && s.Tokens[n].Type == token.NEWLINE {
+		n--
+	}
+	return n >= 0 && (s.Tokens[n].Type == token.FUNCTION_NAME || s.Tokens[n].Type == token.FUNCTION)
+}
+
+// Returns the reserved word of tokenType eg fi for FI
+func reservedLexeme(tokenType token.TokenType) string {
+	for lexeme, reserved := range RESERVED_WORDS {
+		if reserved == tokenType {
+			return lexeme
 		}
+	}
+	return strings.ToLower(string(tokenType))
+}
+
+// Checks if tokenType closes a compound command eg fi
+func closing(tokenType token.TokenType) bool {
+	for _, closing := range BLOCK_ENDS {
+		if tokenType == closing {
+			return true
+		}
+	}
+	return false
+}
 
-		if s.isAtEnd() && s.source[len(s.source)-1] != '\\' {
-			s.current--
+// Returns the type of the last token scanned, "" if there is none
+func (s *Scanner) lastType() token.TokenType {
+	if len(s.Tokens) == 0 {
+		return ""
+	}
+	return s.Tokens[len(s.Tokens)-1].Type
+}
+
+// Checks if the last token closes a compound command eg fi, skipping the
+// redirections that follow it eg fi > out
+func (s *Scanner) afterBlock() bool {
+	n := len(s.Tokens) - 1
+	for n >= 1 && s.Tokens[n].Type == token.ARG && slices.Contains(token.REDIRECTIONS, s.Tokens[n-1].Type) {
+		n -= 2
+	}
+	if n < 0 {
+		return false
+	}
+
+	return closing(s.Tokens[n].Type)
+}
+
+// Records that a command was found in the innermost open block
+func (s *Scanner) command() {
+	if len(s.blocks) > 0 {
+		s.blocks[len(s.blocks)-1].empty = false
+	}
+}
+
+// Scans a ((expr)) command up to the matching )). The lexeme is the
+// expression and the raw text the whole command.
+func (s *Scanner) arithmeticCommand() {
+	s.advance() // Second (
+	forHeader := s.lastType() == token.FOR
+
+	depth := 0
+	for {
+		if s.isAtEnd() {
+			s.eieneErrors.IncompleteInputError("))")
+			return
 		}
 
-		for s.peek() != ' ' && !s.isAtEnd() {
-			if s.peek() == '\\' && s.peekNext() != rune(0) {
-				// Remove the current \ and replace with next character
-				s.source = append(s.source[:s.current], s.source[s.current+1:]...)
-			} else if SPECIAL_CHARS_MAP[s.peek()] || s.peekNext() == rune(0) {
+		c := s.advance()
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			if depth == 0 && s.peek() == ')' {
+				s.advance()
 				break
 			}
+			depth--
+		}
+	}
+
+	s.command()
+	s.Tokens = append(s.Tokens, token.Token{
+		Type:   token.ARITHMETIC_CMD,
+		Lexeme: string(s.source[s.start+2 : s.current-2]),
+		Raw:    string(s.source[s.start:s.current]),
+		Pos:    s.position(s.start),
+	})
+
+	// Only operators and redirections follow the command, do can follow
+	// the header of a for loop
+	s.flags.newCmd = forHeader
+}
+
+// Adds a redirection operator. s.current is after the first character
+// of the operator, s.start at the file descriptor redirected if given.
+// Reports an error if no file follows the operator.
+func (s *Scanner) redirection() {
+	var tokenType token.TokenType
+
+	switch s.source[s.current-1] {
+	case '<':
+		tokenType = token.LESS
+		if s.peek() == '>' {
+			tokenType = token.LESSGREAT
+			s.advance()
+		} else if s.peek() == '&' {
+			tokenType = token.LESSAND
 			s.advance()
 		}
-		s.flags.slashFound = true
 
-		fallthrough
+	case '>':
+		tokenType = token.GREAT
+		if s.peek() == '>' {
+			tokenType = token.DGREAT
+			s.advance()
+		} else if s.peek() == '&' {
+			tokenType = token.GREATAND
+			s.advance()
+		}
 
-	// Command and arguments
-	default:
-		for s.peek() != ' ' && s.peek() != '\n' && s.peek() != '\\' && !SPECIAL_CHARS_MAP[s.peek()] && !s.isAtEnd() {
+	case '&':
+		s.advance() // >
+		tokenType = token.ANDGREAT
+		if s.peek() == '>' {
+			tokenType = token.ANDDGREAT
 			s.advance()
 		}
+	}
 
-		if s.flags.newCmd {
-			s.addToken(token.PROG_NAME)
+	s.addToken(tokenType)
+	s.command()
 
-			// Tokens after this one will be arguments or operators
-			s.flags.newCmd = false
-		} else if !s.flags.spaceFound && s.flags.slashFound {
-			s.updatePreviousToken()
-		} else {
-			s.addToken(token.ARG)
+	// The file redirected to must follow
+	idx := s.current
+	for idx < len(s.source) && (s.source[idx] == ' ' || s.source[idx] == '\t' || s.source[idx] == '\r') {
+		idx++
+	}
+
+	if idx == len(s.source) || s.source[idx] == '\n' {
+		s.eieneErrors.ParseError(s.position(idx), "newline")
+		return
+	}
+	if SPECIAL_CHARS_MAP[s.source[idx]] || REDIRECTION_CHARS_MAP[s.source[idx]] {
+		s.eieneErrors.ParseError(s.position(idx), string(s.source[idx]))
+		return
+	}
+
+	s.flags.redirectionTarget = true
+}
+
+// Scans a single quoted string and writes its content to value.
+func (s *Scanner) singleQuoted(value *strings.Builder) {
+	s.advance() // Opening '
+
+	for s.peek() != '\'' {
+		if s.isAtEnd() {
+			s.eieneErrors.IncompleteInputError("'")
+			return
+		}
+		value.WriteRune(s.advance())
+	}
+
+	s.advance() // Closing '
+}
+
+// Scans a double quoted string and writes its content to value.
+// A back slash only escapes $, `, ", \ and newline, otherwise it is kept.
+func (s *Scanner) doubleQuoted(value *strings.Builder) {
+	s.advance() // Opening "
+
+	for s.peek() != '"' && !s.eieneErrors.HadError {
+		if s.isAtEnd() {
+			s.eieneErrors.IncompleteInputError("\"")
+			return
+		}
+
+		if s.peek() == '$' && s.peekNext() == '(' {
+			s.commandSubstitution(value)
+			continue
+		}
+		if s.peek() == '$' && s.peekNext() == '{' {
+			s.parameterExpansion(value, true)
+			continue
+		}
+		if s.peek() == '`' {
+			s.backquoted(value)
+			continue
+		}
+
+		c := s.advance()
+		if c == '\\' {
+			switch s.peek() {
+			case '$', '`', '"', '\\':
+				c = s.advance()
+			case '\n':
+				s.advance()
+				continue
+			}
+		}
+		value.WriteRune(c)
+	}
+
+	s.advance() // Closing "
+}
+
+// Scans a $(...) command substitution and writes it to value as is.
+// Nested substitutions and quoted parentheses are skipped over.
+func (s *Scanner) commandSubstitution(value *strings.Builder) {
+	value.WriteRune(s.advance()) // $
+	value.WriteRune(s.advance()) // (
+
+	depth := 1
+	for depth > 0 && !s.eieneErrors.HadError {
+		if s.isAtEnd() {
+			s.eieneErrors.IncompleteInputError(")")
+			return
 		}
 
-		s.flags.spaceFound = false
+		c := s.peek()
+		switch {
+		case c == '\\':
+			value.WriteRune(s.advance())
+			if !s.isAtEnd() {
+				value.WriteRune(s.advance())
+			}
+
+		case c == '\'' || c == '"':
+			s.rawQuoted(value)
+
+		case c == '$' && s.peekNext() == '(':
+			s.commandSubstitution(value)
+
+		case c == '`':
+			s.backquoted(value)
+
+		default:
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				depth--
+			}
+			value.WriteRune(s.advance())
+		}
+	}
+}
+
+// Scans a `...` command substitution and writes it to value as is.
+// Back quotes escaped with a back slash do not end it.
+func (s *Scanner) backquoted(value *strings.Builder) {
+	value.WriteRune(s.advance()) // Opening `
+
+	for {
+		if s.isAtEnd() {
+			s.eieneErrors.IncompleteInputError("`")
+			return
+		}
+
+		c := s.advance()
+		value.WriteRune(c)
+		if c == '`' {
+			return
+		}
+		if c == '\\' && !s.isAtEnd() {
+			value.WriteRune(s.advance())
+		}
+	}
+}
+
+// Scans a ${...} parameter expansion and writes it to value as is.
+// Nested expansions and quoted braces are skipped over. Single quotes are
+// not quotes inside double quotes.
+func (s *Scanner) parameterExpansion(value *strings.Builder, inDoubleQuotes bool) {
+	value.WriteRune(s.advance()) // $
+	value.WriteRune(s.advance()) // {
+
+	for !s.eieneErrors.HadError {
+		if s.isAtEnd() {
+			s.eieneErrors.IncompleteInputError("}")
+			return
+		}
+
+		c := s.peek()
+		switch {
+		case c == '}':
+			value.WriteRune(s.advance())
+			return
+
+		case c == '\\':
+			value.WriteRune(s.advance())
+			if !s.isAtEnd() {
+				value.WriteRune(s.advance())
+			}
+
+		case c == '"' || (c == '\'' && !inDoubleQuotes):
+			s.rawQuoted(value)
+
+		case c == '$' && s.peekNext() == '(':
+			s.commandSubstitution(value)
+
+		case c == '$' && s.peekNext() == '{':
+			s.parameterExpansion(value, inDoubleQuotes)
+
+		case c == '`':
+			s.backquoted(value)
+
+		default:
+			value.WriteRune(s.advance())
+		}
+	}
+}
+
+// Scans a quoted string inside a command substitution or a parameter
+// expansion and writes it to value together with its quotes.
+func (s *Scanner) rawQuoted(value *strings.Builder) {
+	quote := s.advance()
+	value.WriteRune(quote)
+
+	for s.peek() != quote && !s.eieneErrors.HadError {
+		if s.isAtEnd() {
+			s.eieneErrors.IncompleteInputError(string(quote))
+			return
+		}
+
+		if quote == '"' && s.peek() == '$' && s.peekNext() == '(' {
+			s.commandSubstitution(value)
+			continue
+		}
+
+		c := s.advance()
+		value.WriteRune(c)
+		if c == '\\' && quote == '"' && !s.isAtEnd() {
+			value.WriteRune(s.advance())
+		}
 	}
 
-	s.flags.slashFound = false
+	if !s.eieneErrors.HadError {
+		value.WriteRune(s.advance())
+	}
+}
+
+// Reads the next line of a command ending with \ and appends it to s.source.
+// Without a reader the input is incomplete.
+func (s *Scanner) continueLine() {
+	if s.reader == nil {
+		s.eieneErrors.IncompleteInputError("line after \\")
+		return
+	}
+
+	line, err := s.reader(">")
+	if err == io.EOF {
+		s.eieneErrors.IncompleteInputError("line after \\")
+		return
+	}
+	if err != nil {
+		s.eieneErrors.HadError = true
+		s.eieneErrors.Errors = append(s.eieneErrors.Errors, err.Error())
+		return
+	}
+
+	// Remove the \ and join the lines
+	s.source = append(s.source[:len(s.source)-1], []rune(line)...)
+	s.eieneErrors.SetSource(string(s.source))
+	s.current--
 }
 
 func (s *Scanner) logicalOperator(tokenType token.TokenType) {
 	s.advance()
+	s.controlOperator(tokenType)
+}
 
+// Adds an operator that must be followed by a command eg && or |.
+// Reports an error if the operator is followed by another operator.
+func (s *Scanner) controlOperator(tokenType token.TokenType) {
 	_current := s.current
 	if s.peek() == ' ' {
 		_current = s.consumeWhitespace()
@@ -200,16 +1080,21 @@ func (s *Scanner) logicalOperator(tokenType token.TokenType) {
 		s.addToken(tokenType)
 		s.flags.newCmd = true
 
-		// Continue reading if the command ends in && or ||
+		// Continue reading if the command ends in &&, || or |
 		if s.peek() == rune(0) {
-			prompt := "cmdand>"
-			if tokenType == token.OR {
-				prompt = "cmdor>"
+			prompt := OPERATOR_PROMPTS[tokenType]
+			if s.reader == nil {
+				s.eieneErrors.IncompleteInputError("command after " + s.Tokens[len(s.Tokens)-1].Lexeme)
+				return
 			}
 
 			// Exit if ^C is pressed or a non-empty command is entered
 			for !s.eieneErrors.HadError {
 				line, err := s.reader(prompt)
+				if err == io.EOF {
+					s.eieneErrors.IncompleteInputError("command after " + s.Tokens[len(s.Tokens)-1].Lexeme)
+					return
+				}
 				if err != nil {
 					s.eieneErrors.HadError = true
 					s.eieneErrors.Errors = append(s.eieneErrors.Errors, err.Error())
@@ -219,6 +1104,7 @@ func (s *Scanner) logicalOperator(tokenType token.TokenType) {
 				line = strings.Trim(line, " \t\r\n")
 				if len(line) > 0 {
 					s.source = append(s.source, []rune(line)...)
+					s.eieneErrors.SetSource(string(s.source))
 					break
 				}
 			}
@@ -230,7 +1116,8 @@ func (s *Scanner) logicalOperator(tokenType token.TokenType) {
 	// If the next character is ; eg &&; it's not an error
 	// unless if the semicolon is repeated eg &&;;. This case
 	// where the error occurs will be handled in the semicolon(;) case
-	if s.peek() == ';' || (!s.isAtEnd() && s.source[_current] == ';') {
+	// A pipe on the other hand needs a command to write to.
+	if tokenType != token.PIPE && (s.peek() == ';' || (!s.isAtEnd() && s.source[_current] == ';')) {
 		s.addToken(tokenType)
 		s.flags.newCmd = true
 		return
@@ -245,7 +1132,34 @@ func (s *Scanner) logicalOperator(tokenType token.TokenType) {
 		s.advance()
 	}
 	error_chars := string(s.source[s.start:s.current])
-	s.eieneErrors.ParseError(error_chars)
+	s.eieneErrors.ParseError(s.position(s.start), error_chars)
+}
+
+// Adds a single & which runs the command before it in the background.
+// Like ; it can end the command line or be followed by another command.
+func (s *Scanner) background() {
+	_current := s.consumeWhitespace()
+
+	// The body of a case clause can end with a command run in the background
+	if s.caseClause() && _current+1 < len(s.source) && s.source[_current] == ';' &&
+		(s.source[_current+1] == ';' || s.source[_current+1] == '&') {
+		s.addToken(token.AMPERSAND)
+		s.flags.newCmd = true
+		return
+	}
+
+	if !s.isAtEnd() && SPECIAL_CHARS_MAP[s.source[_current]] {
+		s.start = _current
+		s.current = _current + 1
+		if SPECIAL_CHARS_MAP[s.peek()] {
+			s.advance()
+		}
+		s.eieneErrors.ParseError(s.position(s.start), string(s.source[s.start:s.current]))
+		return
+	}
+
+	s.addToken(token.AMPERSAND)
+	s.flags.newCmd = true
 }
 
 // Consumes whitespace from s.current to the next non-whitespace character.
@@ -271,25 +1185,33 @@ func (s *Scanner) addToken(tokenType token.TokenType) {
 		s.Tokens = append(s.Tokens, token.Token{
 			Type:   tokenType,
 			Lexeme: value,
+			Pos:    s.position(s.start),
 		})
 	}
 }
 
-// Updates the previous token after a \ is encountered and
-// no space is found between the last character in previous line and
-// the first in the next line
-func (s *Scanner) updatePreviousToken() {
-	// Error if called with s.Tokens being empty
-	if len(s.Tokens) == 0 {
-		s.eieneErrors.ParseError(
-			"Error in scanner. Not configured properly" +
-				"function: s.updatePreviousToken",
-		)
-		return
+// Returns the position of the character at index idx of s.source.
+// The last computed position is cached since tokens are mostly looked up
+// in the order they appear.
+func (s *Scanner) position(idx int) token.Position {
+	if idx < s.cursorIdx {
+		s.cursor = token.Position{Line: 1, Column: 1, Offset: 0}
+		s.cursorIdx = 0
 	}
 
-	value := strings.Trim(string(s.source[s.start:s.current]), " ")
-	s.Tokens[len(s.Tokens)-1].Lexeme = s.Tokens[len(s.Tokens)-1].Lexeme + value
+	for ; s.cursorIdx < idx && s.cursorIdx < len(s.source); s.cursorIdx++ {
+		c := s.source[s.cursorIdx]
+
+		s.cursor.Offset += utf8.RuneLen(c)
+		if c == '\n' {
+			s.cursor.Line++
+			s.cursor.Column = 1
+		} else {
+			s.cursor.Column++
+		}
+	}
+
+	return s.cursor
 }
 
 // Gets next character to be scanned.
@@ -328,6 +1250,44 @@ func (s Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
 
+// Checks if value is made up of digits only.
+func isNumber(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks if name can be the name of a function. Names are unquoted words
+// without expansions eg my-func or _f.
+func isFunctionName(name string) bool {
+	return name != "" && !isNumber(name) && !strings.ContainsAny(name, "$`'\"\\=(){}[]*?~/")
+}
+
+// Checks if the word written as raw is an assignment eg NAME=value.
+// The name must be unquoted and start with a letter or an underscore.
+func isAssignment(raw string) bool {
+	name, _, found := strings.Cut(raw, "=")
+	if !found || name == "" {
+		return false
+	}
+
+	for n, c := range name {
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isLetter && (n == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Creates a map from a slice
 // The created map uses the slice values as keys and sets the value of each key to true.
 // The produced map can be used to check if a certain value is found in the parent slice.
@@ -43,17 +43,26 @@ func readerFuncGenerator(readValues []string) scanner.ReaderFunc {
 }
 
 // Scan tokens from commands that may span multiple lines.
+// Positions are cleared, they are checked in TestTokenPositions.
 func scanTokensMultilineHelper(cmd string, reader scanner.ReaderFunc) []token.Token {
 	EieneErrors.ResetErrors()
 	_scanner := scanner.NewScanner(cmd, EieneErrors, reader)
-	return _scanner.ScanTokens()
+	return clearPositions(_scanner.ScanTokens())
 }
 
 // Scan tokens from single line commands
 func scanTokensHelper(cmd string) []token.Token {
-	EieneErrors.ResetErrors()
-	_scanner := scanner.NewScanner(cmd, EieneErrors, readerFuncGenerator([]string{"EOF"}))
-	return _scanner.ScanTokens()
+	return scanTokensMultilineHelper(cmd, readerFuncGenerator([]string{"EOF"}))
+}
+
+// Clears the positions and source text of tokens to compare them with
+// tokens made by newToken
+func clearPositions(tokens []token.Token) []token.Token {
+	for i := range tokens {
+		tokens[i].Pos = token.Position{}
+		tokens[i].Raw = ""
+	}
+	return tokens
 }
 
 func newToken(tokenType token.TokenType, lexeme string) token.Token {
@@ -212,6 +221,35 @@ func TestLogicalCommandContinuation(t *testing.T) {
 	}
 }
 
+func TestTokenPositions(t *testing.T) {
+	cmd := "ls -a &&\t'x y'\n  é;cd"
+
+	EieneErrors.ResetErrors()
+	result := scanner.NewScanner(cmd, EieneErrors, nil).ScanTokens()
+
+	expected := []token.Position{
+		{Line: 1, Column: 1, Offset: 0},
+		{Line: 1, Column: 4, Offset: 3},
+		{Line: 1, Column: 7, Offset: 6},
+		{Line: 1, Column: 10, Offset: 9},
+		{Line: 1, Column: 15, Offset: 14},
+		{Line: 2, Column: 3, Offset: 17},
+		{Line: 2, Column: 4, Offset: 19},
+		{Line: 2, Column: 5, Offset: 20},
+		{Line: 2, Column: 7, Offset: 22},
+	}
+
+	if len(result) != len(expected) {
+		t.Fatalf("Scan('%s') got %v. Expected %d tokens", cmd, result, len(expected))
+	}
+
+	for i, tok := range result {
+		if tok.Pos != expected[i] {
+			t.Errorf("Scan('%s') token %v got position %v. Expected %v", cmd, tok, tok.Pos, expected[i])
+		}
+	}
+}
+
 func TestSemicolon(t *testing.T) {
 	cmd := "cd ; ls -a"
 	result := scanTokensHelper(cmd)
@@ -400,7 +438,7 @@ func TestEmptyCommand(t *testing.T) {
 		{"   ", []token.Token{newToken(token.EOF, "")}},
 		{"\t", []token.Token{newToken(token.EOF, "")}},
 		{"\r", []token.Token{newToken(token.EOF, "")}},
-		{"\n", []token.Token{newToken(token.EOF, "")}},
+		{"\n", []token.Token{newToken(token.NEWLINE, "\n"), newToken(token.EOF, "")}},
 	}
 
 	for _, test := range tests {
@@ -413,13 +451,52 @@ func TestEmptyCommand(t *testing.T) {
 }
 
 func TestBackgroundExecution(t *testing.T) {
-	expectedErrorText := "Background execution (&) Not implemented"
+	tests := []struct {
+		cmd      string
+		expected []token.Token
+	}{
+		{"cd &", []token.Token{
+			newToken(token.PROG_NAME, "cd"),
+			newToken(token.AMPERSAND, "&"),
+			newToken(token.EOF, ""),
+		}},
+		{"cd && ls &", []token.Token{
+			newToken(token.PROG_NAME, "cd"),
+			newToken(token.AND, "&&"),
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.AMPERSAND, "&"),
+			newToken(token.EOF, ""),
+		}},
+		{"sleep 1& ls -a &   ", []token.Token{
+			newToken(token.PROG_NAME, "sleep"),
+			newToken(token.ARG, "1"),
+			newToken(token.AMPERSAND, "&"),
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.ARG, "-a"),
+			newToken(token.AMPERSAND, "&"),
+			newToken(token.EOF, ""),
+		}},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Scan('%s') got %v. Expected %v", test.cmd, result, test.expected)
+		}
+	}
+}
+
+func TestBackgroundParseError(t *testing.T) {
+	errorTextPrefix := "Parse error near "
 
 	tests := []struct {
-		cmd string
+		cmd, expectedErrorText string
 	}{
-		{"cd &"},
-		{"cd && ls &"},
+		{"cd & ;", errorTextPrefix + ";"},
+		{"cd &;", errorTextPrefix + ";"},
+		{"cd & | ls", errorTextPrefix + "|"},
+		{"cd & && ls", errorTextPrefix + "&&"},
 	}
 
 	for _, test := range tests {
@@ -430,20 +507,179 @@ func TestBackgroundExecution(t *testing.T) {
 		}
 
 		errorText := EieneErrors.Error()
-		if errorText != expectedErrorText {
-			t.Errorf("Scan('%s') got error message %s. Expected %s.", test.cmd, errorText, expectedErrorText)
+		if errorText != test.expectedErrorText {
+			t.Errorf("Scan('%s') got error message %s. Expected %s.", test.cmd, errorText, test.expectedErrorText)
 		}
 	}
 }
 
 func TestPiping(t *testing.T) {
-	expectedErrorText := "Piping (|) Not implemented"
+	tests := []struct {
+		cmd      string
+		reader   scanner.ReaderFunc
+		expected []token.Token
+	}{
+		{
+			"ls | cat",
+			readerFuncGenerator([]string{"EOF"}),
+			[]token.Token{
+				newToken(token.PROG_NAME, "ls"),
+				newToken(token.PIPE, "|"),
+				newToken(token.PROG_NAME, "cat"),
+				newToken(token.EOF, ""),
+			},
+		},
+		{
+			"ls -a|grep x|wc -l&&cd",
+			readerFuncGenerator([]string{"EOF"}),
+			[]token.Token{
+				newToken(token.PROG_NAME, "ls"),
+				newToken(token.ARG, "-a"),
+				newToken(token.PIPE, "|"),
+				newToken(token.PROG_NAME, "grep"),
+				newToken(token.ARG, "x"),
+				newToken(token.PIPE, "|"),
+				newToken(token.PROG_NAME, "wc"),
+				newToken(token.ARG, "-l"),
+				newToken(token.AND, "&&"),
+				newToken(token.PROG_NAME, "cd"),
+				newToken(token.EOF, ""),
+			},
+		},
+		{
+			// Continue reading if the command ends with |
+			"ls |",
+			readerFuncGenerator([]string{" ", "cat"}),
+			[]token.Token{
+				newToken(token.PROG_NAME, "ls"),
+				newToken(token.PIPE, "|"),
+				newToken(token.PROG_NAME, "cat"),
+				newToken(token.EOF, ""),
+			},
+		},
+		{
+			"ls |",
+			readerFuncGenerator([]string{"^C"}),
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		result := scanTokensMultilineHelper(test.cmd, test.reader)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("[%d] Scan('%s') got %v. Expected %v", i, test.cmd, result, test.expected)
+		}
+	}
+}
+
+func TestPipeParseError(t *testing.T) {
+	errorTextPrefix := "Parse error near "
+
+	tests := []struct {
+		cmd, expectedErrorText string
+	}{
+		{"ls | | cat", errorTextPrefix + "|"},
+		{"ls |; cat", errorTextPrefix + ";"},
+		{"ls | && cat", errorTextPrefix + "&&"},
+		{"ls && | cat", errorTextPrefix + "|"},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if result != nil {
+			t.Errorf("Scan('%s') got %v. Expected nil", test.cmd, result)
+		}
+
+		errorText := EieneErrors.Error()
+		if errorText != test.expectedErrorText {
+			t.Errorf("Scan('%s') got error message %s. Expected %s.", test.cmd, errorText, test.expectedErrorText)
+		}
+	}
+}
+
+func TestRedirections(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		expected []token.Token
+	}{
+		{"ls > out.txt", []token.Token{
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.GREAT, ">"),
+			newToken(token.ARG, "out.txt"),
+			newToken(token.EOF, ""),
+		}},
+		{"ls -a>>out 2>&1 <in", []token.Token{
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.ARG, "-a"),
+			newToken(token.DGREAT, ">>"),
+			newToken(token.ARG, "out"),
+			newToken(token.GREATAND, "2>&"),
+			newToken(token.ARG, "1"),
+			newToken(token.LESS, "<"),
+			newToken(token.ARG, "in"),
+			newToken(token.EOF, ""),
+		}},
+		{">out 2>err ls &>all <>rw", []token.Token{
+			newToken(token.GREAT, ">"),
+			newToken(token.ARG, "out"),
+			newToken(token.GREAT, "2>"),
+			newToken(token.ARG, "err"),
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.ANDGREAT, "&>"),
+			newToken(token.ARG, "all"),
+			newToken(token.LESSGREAT, "<>"),
+			newToken(token.ARG, "rw"),
+			newToken(token.EOF, ""),
+		}},
+		{`echo 2 ">" 2\> a2>b '2'>c`, []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "2"),
+			newToken(token.ARG, ">"),
+			newToken(token.ARG, "2>"),
+			newToken(token.ARG, "a2"),
+			newToken(token.GREAT, ">"),
+			newToken(token.ARG, "b"),
+			newToken(token.ARG, "2"),
+			newToken(token.GREAT, ">"),
+			newToken(token.ARG, "c"),
+			newToken(token.EOF, ""),
+		}},
+		{"ls 2>/dev/null | cat <&0 &>>log", []token.Token{
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.GREAT, "2>"),
+			newToken(token.ARG, "/dev/null"),
+			newToken(token.PIPE, "|"),
+			newToken(token.PROG_NAME, "cat"),
+			newToken(token.LESSAND, "<&"),
+			newToken(token.ARG, "0"),
+			newToken(token.ANDDGREAT, "&>>"),
+			newToken(token.ARG, "log"),
+			newToken(token.EOF, ""),
+		}},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Scan('%s') got %v. Expected %v", test.cmd, result, test.expected)
+		}
+	}
+}
+
+func TestRedirectionWithoutFileParseError(t *testing.T) {
+	errorTextPrefix := "Parse error near "
 
 	tests := []struct {
-		cmd string
+		cmd, expectedErrorText string
 	}{
-		{"cd |"},
-		{"ls | cat"},
+		{"ls >", errorTextPrefix + "newline"},
+		{"ls 2>  ", errorTextPrefix + "newline"},
+		{"ls > ; cd", errorTextPrefix + ";"},
+		{"ls >> | cat", errorTextPrefix + "|"},
+		{"ls > > out", errorTextPrefix + ">"},
 	}
 
 	for _, test := range tests {
@@ -454,8 +690,8 @@ func TestPiping(t *testing.T) {
 		}
 
 		errorText := EieneErrors.Error()
-		if errorText != expectedErrorText {
-			t.Errorf("Scan('%s') got error message %s. Expected %s.", test.cmd, errorText, expectedErrorText)
+		if errorText != test.expectedErrorText {
+			t.Errorf("Scan('%s') got error message %s. Expected %s.", test.cmd, errorText, test.expectedErrorText)
 		}
 	}
 }
@@ -474,7 +710,8 @@ func TestCommandWithComment(t *testing.T) {
 		}},
 		{"cd #this is a comment\n ls", []token.Token{
 			newToken(token.PROG_NAME, "cd"),
-			newToken(token.ARG, "ls"),
+			newToken(token.NEWLINE, "\n"),
+			newToken(token.PROG_NAME, "ls"),
 			newToken(token.EOF, ""),
 		}},
 	}
@@ -517,11 +754,12 @@ func TestSlashCanEscapeNextCharacter(t *testing.T) {
 		}},
 		{"cd \\ \\\\one", []token.Token{
 			newToken(token.PROG_NAME, "cd"),
-			newToken(token.ARG, "\\one"),
+			newToken(token.ARG, " \\one"),
 			newToken(token.EOF, ""),
 		}},
 		{"cd \\   \\ls", []token.Token{
 			newToken(token.PROG_NAME, "cd"),
+			newToken(token.ARG, " "),
 			newToken(token.ARG, "ls"),
 			newToken(token.EOF, ""),
 		}},
@@ -540,6 +778,176 @@ func TestSlashCanEscapeNextCharacter(t *testing.T) {
 	}
 }
 
+func TestQuotedWords(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		expected []token.Token
+	}{
+		{`echo "hello world"`, []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "hello world"),
+			newToken(token.EOF, ""),
+		}},
+		{`git commit -m 'fix bug'`, []token.Token{
+			newToken(token.PROG_NAME, "git"),
+			newToken(token.ARG, "commit"),
+			newToken(token.ARG, "-m"),
+			newToken(token.ARG, "fix bug"),
+			newToken(token.EOF, ""),
+		}},
+		{`echo 'a && b; c | d'`, []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "a && b; c | d"),
+			newToken(token.EOF, ""),
+		}},
+		{`echo "a && b; c | d"&&ls`, []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "a && b; c | d"),
+			newToken(token.AND, "&&"),
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.EOF, ""),
+		}},
+		{`echo a"b c"'d'`, []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "ab cd"),
+			newToken(token.EOF, ""),
+		}},
+		{`"my prog" '' ""`, []token.Token{
+			newToken(token.PROG_NAME, "my prog"),
+			newToken(token.ARG, ""),
+			newToken(token.ARG, ""),
+			newToken(token.EOF, ""),
+		}},
+		{`echo '\"' "\"\\\$\a" 'it'\''s'`, []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, `\"`),
+			newToken(token.ARG, `"\$\a`),
+			newToken(token.ARG, "it's"),
+			newToken(token.EOF, ""),
+		}},
+		{`echo "#not a comment"`, []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "#not a comment"),
+			newToken(token.EOF, ""),
+		}},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Scan('%s') got %v. Expected %v", test.cmd, result, test.expected)
+		}
+	}
+}
+
+func TestCommandSubstitutionIsOneWord(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		expected []token.Token
+	}{
+		{`cd $(git rev-parse --show-toplevel)`, []token.Token{
+			newToken(token.PROG_NAME, "cd"),
+			newToken(token.ARG, "$(git rev-parse --show-toplevel)"),
+			newToken(token.EOF, ""),
+		}},
+		{`echo a$(echo "b c" | tr ')' x; echo $(ls))d`, []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, `a$(echo "b c" | tr ')' x; echo $(ls))d`),
+			newToken(token.EOF, ""),
+		}},
+		{`echo "$(echo ")")" $`, []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, `$(echo ")")`),
+			newToken(token.ARG, "$"),
+			newToken(token.EOF, ""),
+		}},
+		{"echo `date +%s; echo \\`ls\\``x \"`echo ' a '`\"", []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "`date +%s; echo \\`ls\\``x"),
+			newToken(token.ARG, "`echo ' a '`"),
+			newToken(token.EOF, ""),
+		}},
+		{"echo $(echo `echo )`)", []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "$(echo `echo )`)"),
+			newToken(token.EOF, ""),
+		}},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Scan('%s') got %v. Expected %v", test.cmd, result, test.expected)
+		}
+	}
+}
+
+func TestUnclosedConstructIsIncompleteInput(t *testing.T) {
+	errorTextPrefix := "Incomplete input, missing "
+
+	tests := []struct {
+		cmd, expectedErrorText string
+	}{
+		{`echo "hello`, errorTextPrefix + `"`},
+		{`echo 'fix bug && ls`, errorTextPrefix + `'`},
+		{`echo "it's`, errorTextPrefix + `"`},
+		{`cd $(git rev-parse`, errorTextPrefix + `)`},
+		{`cd $(echo "$(pwd)`, errorTextPrefix + `"`},
+		{`echo "$(ls)`, errorTextPrefix + `"`},
+		{`echo ${HOME`, errorTextPrefix + `}`},
+		{`echo ${A:-'}`, errorTextPrefix + `'`},
+		{"echo `date", errorTextPrefix + "`"},
+		{"((i + (1)", errorTextPrefix + "))"},
+		{"if true; then echo", errorTextPrefix + "fi"},
+		{"if true; then\n  if false; then :; fi", errorTextPrefix + "fi"},
+		{"while true; do echo", errorTextPrefix + "done"},
+		{"until false\ndo\n  if true; then break; fi", errorTextPrefix + "done"},
+		{"for x in a b", errorTextPrefix + "done"},
+		{"case x in", errorTextPrefix + "esac"},
+		{"case x in\n  a|b)\n    echo;;", errorTextPrefix + "esac"},
+		{"for ((;;)); do\n  echo", errorTextPrefix + "done"},
+		{"{ echo a }", errorTextPrefix + "}"},
+		{"f() {\n  echo", errorTextPrefix + "}"},
+		{"f()", errorTextPrefix + "function body"},
+		{"function f\n", errorTextPrefix + "function body"},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if result != nil {
+			t.Errorf("Scan('%s') got %v. Expected nil", test.cmd, result)
+		}
+		if !EieneErrors.HadIncompleteInput {
+			t.Errorf("Scan('%s') was expected to be incomplete", test.cmd)
+		}
+
+		errorText := EieneErrors.Error()
+		if errorText != test.expectedErrorText {
+			t.Errorf("Scan('%s') got error message %s. Expected %s.", test.cmd, errorText, test.expectedErrorText)
+		}
+	}
+}
+
+func TestIncompleteInputCanBeCompleted(t *testing.T) {
+	cmd := "echo \"hello\nworld\" 'a\nb' $(ls\n-a)"
+	result := scanTokensHelper(cmd)
+
+	expected := []token.Token{
+		newToken(token.PROG_NAME, "echo"),
+		newToken(token.ARG, "hello\nworld"),
+		newToken(token.ARG, "a\nb"),
+		newToken(token.ARG, "$(ls\n-a)"),
+		newToken(token.EOF, ""),
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Scan('%s') got %v. Expected %v", cmd, result, expected)
+	}
+}
+
 func TestBackSlashAtEndOfCommandContinuesReading(t *testing.T) {
 	tests := []struct {
 		cmd      string
@@ -622,3 +1030,366 @@ func TestBackSlashAtEndOfCommandContinuesReading(t *testing.T) {
 		}
 	}
 }
+
+func TestWordSourceText(t *testing.T) {
+	cmd := `echo "a b"'c' \$? $(ls ")") 2>'err file'`
+
+	EieneErrors.ResetErrors()
+	result := scanner.NewScanner(cmd, EieneErrors, nil).ScanTokens()
+
+	expected := []string{"echo", `"a b"'c'`, `\$?`, `$(ls ")")`, "", "'err file'", ""}
+
+	if len(result) != len(expected) {
+		t.Fatalf("Scan('%s') got %v. Expected %d tokens", cmd, result, len(expected))
+	}
+
+	for i, tok := range result {
+		if tok.Raw != expected[i] {
+			t.Errorf("Scan('%s') token %v got source text %q. Expected %q", cmd, tok, tok.Raw, expected[i])
+		}
+	}
+}
+
+func TestAssignmentWords(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		expected []token.Token
+	}{
+		{"FOO=1 BAR='a b' make test", []token.Token{
+			newToken(token.ASSIGNMENT_WORD, "FOO=1"),
+			newToken(token.ASSIGNMENT_WORD, "BAR=a b"),
+			newToken(token.PROG_NAME, "make"),
+			newToken(token.ARG, "test"),
+			newToken(token.EOF, ""),
+		}},
+		{"A=1; _b2=", []token.Token{
+			newToken(token.ASSIGNMENT_WORD, "A=1"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.ASSIGNMENT_WORD, "_b2="),
+			newToken(token.EOF, ""),
+		}},
+		{"echo A=1", []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "A=1"),
+			newToken(token.EOF, ""),
+		}},
+		{"'A'=1 1A=2 =3", []token.Token{
+			newToken(token.PROG_NAME, "A=1"),
+			newToken(token.ARG, "1A=2"),
+			newToken(token.ARG, "=3"),
+			newToken(token.EOF, ""),
+		}},
+		{">out A=1 ls", []token.Token{
+			newToken(token.GREAT, ">"),
+			newToken(token.ARG, "out"),
+			newToken(token.ASSIGNMENT_WORD, "A=1"),
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.EOF, ""),
+		}},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Scan('%s') got %v. Expected %v", test.cmd, result, test.expected)
+		}
+	}
+}
+
+func TestParameterExpansionIsOneWord(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		expected []token.Token
+	}{
+		{"echo ${A:-a b} \"${B#*}\"x", []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "${A:-a b}"),
+			newToken(token.ARG, "${B#*}x"),
+			newToken(token.EOF, ""),
+		}},
+		{"echo ${A:-'}'} ${B:-${C}|} \"${D:-'}\"", []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "${A:-'}'}"),
+			newToken(token.ARG, "${B:-${C}|}"),
+			newToken(token.ARG, "${D:-'}"),
+			newToken(token.EOF, ""),
+		}},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Scan('%s') got %v. Expected %v", test.cmd, result, test.expected)
+		}
+	}
+}
+
+func TestArithmeticCommand(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		expected []token.Token
+	}{
+		{"((i += (2 * 3))) && echo $((i))", []token.Token{
+			newToken(token.ARITHMETIC_CMD, "i += (2 * 3)"),
+			newToken(token.AND, "&&"),
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "$((i))"),
+			newToken(token.EOF, ""),
+		}},
+		{"echo ((1))", []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "((1))"),
+			newToken(token.EOF, ""),
+		}},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Scan('%s') got %v. Expected %v", test.cmd, result, test.expected)
+		}
+	}
+}
+
+func TestReservedWords(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		expected []token.Token
+	}{
+		{"if true; then echo if; elif false\nthen :; else echo fi; fi > out", []token.Token{
+			newToken(token.IF, "if"),
+			newToken(token.PROG_NAME, "true"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.THEN, "then"),
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "if"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.ELIF, "elif"),
+			newToken(token.PROG_NAME, "false"),
+			newToken(token.NEWLINE, "\n"),
+			newToken(token.THEN, "then"),
+			newToken(token.PROG_NAME, ":"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.ELSE, "else"),
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "fi"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.FI, "fi"),
+			newToken(token.GREAT, ">"),
+			newToken(token.ARG, "out"),
+			newToken(token.EOF, ""),
+		}},
+		{"'if' A=1 if; FOO=1 fi", []token.Token{
+			newToken(token.PROG_NAME, "if"),
+			newToken(token.ARG, "A=1"),
+			newToken(token.ARG, "if"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.ASSIGNMENT_WORD, "FOO=1"),
+			newToken(token.PROG_NAME, "fi"),
+			newToken(token.EOF, ""),
+		}},
+		{"if if true; then :; fi; then :; fi && ls", []token.Token{
+			newToken(token.IF, "if"),
+			newToken(token.IF, "if"),
+			newToken(token.PROG_NAME, "true"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.THEN, "then"),
+			newToken(token.PROG_NAME, ":"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.FI, "fi"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.THEN, "then"),
+			newToken(token.PROG_NAME, ":"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.FI, "fi"),
+			newToken(token.AND, "&&"),
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.EOF, ""),
+		}},
+		{"while true; do echo done; done; until false\ndo break; done < in", []token.Token{
+			newToken(token.WHILE, "while"),
+			newToken(token.PROG_NAME, "true"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.DO, "do"),
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "done"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.DONE, "done"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.UNTIL, "until"),
+			newToken(token.PROG_NAME, "false"),
+			newToken(token.NEWLINE, "\n"),
+			newToken(token.DO, "do"),
+			newToken(token.PROG_NAME, "break"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.DONE, "done"),
+			newToken(token.LESS, "<"),
+			newToken(token.ARG, "in"),
+			newToken(token.EOF, ""),
+		}},
+		{"for in in in do; do echo in; done\nfor x\ndo :; done", []token.Token{
+			newToken(token.FOR, "for"),
+			newToken(token.ARG, "in"),
+			newToken(token.IN, "in"),
+			newToken(token.ARG, "in"),
+			newToken(token.ARG, "do"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.DO, "do"),
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "in"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.DONE, "done"),
+			newToken(token.NEWLINE, "\n"),
+			newToken(token.FOR, "for"),
+			newToken(token.ARG, "x"),
+			newToken(token.NEWLINE, "\n"),
+			newToken(token.DO, "do"),
+			newToken(token.PROG_NAME, ":"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.DONE, "done"),
+			newToken(token.EOF, ""),
+		}},
+		{"case $x in (a|'b c')  echo a;; *.go) ;& \"esac\") ls &;;&\n*) esac", []token.Token{
+			newToken(token.CASE, "case"),
+			newToken(token.ARG, "$x"),
+			newToken(token.IN, "in"),
+			newToken(token.PATTERN, "a"),
+			newToken(token.PIPE, "|"),
+			newToken(token.PATTERN, "b c"),
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "a"),
+			newToken(token.DSEMI, ";;"),
+			newToken(token.PATTERN, "*.go"),
+			newToken(token.SEMIAND, ";&"),
+			newToken(token.PATTERN, "esac"),
+			newToken(token.PROG_NAME, "ls"),
+			newToken(token.AMPERSAND, "&"),
+			newToken(token.DSEMIAND, ";;&"),
+			newToken(token.NEWLINE, "\n"),
+			newToken(token.PATTERN, "*"),
+			newToken(token.ESAC, "esac"),
+			newToken(token.EOF, ""),
+		}},
+		{"case in in esac", []token.Token{
+			newToken(token.CASE, "case"),
+			newToken(token.ARG, "in"),
+			newToken(token.IN, "in"),
+			newToken(token.ESAC, "esac"),
+			newToken(token.EOF, ""),
+		}},
+		{"for ((i = 0; i < 3; i++)) do :; done", []token.Token{
+			newToken(token.FOR, "for"),
+			newToken(token.ARITHMETIC_CMD, "i = 0; i < 3; i++"),
+			newToken(token.DO, "do"),
+			newToken(token.PROG_NAME, ":"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.DONE, "done"),
+			newToken(token.EOF, ""),
+		}},
+		{"f() { echo {; } > out; function g\n{ :; }", []token.Token{
+			newToken(token.FUNCTION_NAME, "f"),
+			newToken(token.LBRACE, "{"),
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "{"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.RBRACE, "}"),
+			newToken(token.GREAT, ">"),
+			newToken(token.ARG, "out"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.FUNCTION, "function"),
+			newToken(token.FUNCTION_NAME, "g"),
+			newToken(token.NEWLINE, "\n"),
+			newToken(token.LBRACE, "{"),
+			newToken(token.PROG_NAME, ":"),
+			newToken(token.SEMICOLON, ";"),
+			newToken(token.RBRACE, "}"),
+			newToken(token.EOF, ""),
+		}},
+		{"echo f() }", []token.Token{
+			newToken(token.PROG_NAME, "echo"),
+			newToken(token.ARG, "f()"),
+			newToken(token.ARG, "}"),
+			newToken(token.EOF, ""),
+		}},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Scan('%s') got %v. Expected %v", test.cmd, result, test.expected)
+		}
+	}
+}
+
+func TestMisplacedReservedWordParseError(t *testing.T) {
+	errorTextPrefix := "Parse error near "
+
+	tests := []struct {
+		cmd, expectedErrorText string
+	}{
+		{"fi", errorTextPrefix + "fi"},
+		{"echo; then", errorTextPrefix + "then"},
+		{"if then :; fi", errorTextPrefix + "then"},
+		{"if true; then fi", errorTextPrefix + "fi"},
+		{"if true; then :; elif true; fi", errorTextPrefix + "fi"},
+		{"if true; then :; else :; else :; fi", errorTextPrefix + "else"},
+		{"if true && then :; fi", errorTextPrefix + "then"},
+		{"if true; then :; fi ls", errorTextPrefix + "ls"},
+		{"if true; then :; fi > out ls", errorTextPrefix + "ls"},
+		{"while true; do :; done 2>&1 <in ls", errorTextPrefix + "ls"},
+		{"done", errorTextPrefix + "done"},
+		{"while true; done", errorTextPrefix + "done"},
+		{"while do :; done", errorTextPrefix + "do"},
+		{"if true; then :; done", errorTextPrefix + "done"},
+		{"while true; do :; fi", errorTextPrefix + "fi"},
+		{"for; do :; done", errorTextPrefix + ";"},
+		{"for\ndo :; done", errorTextPrefix + "newline"},
+		{"for x y; do :; done", errorTextPrefix + "y"},
+		{"for x; echo; do :; done", errorTextPrefix + "echo"},
+		{"for x in a > out; do :; done", errorTextPrefix + ">"},
+		{"for x in a; do done", errorTextPrefix + "done"},
+		{"for ((i = 0; i < 3)); do :; done", errorTextPrefix + "((i = 0; i < 3))"},
+		{"for ((;;)) :; done", errorTextPrefix + ":"},
+		{"in x; do :; done", errorTextPrefix + "do"},
+		{"echo a;; echo", errorTextPrefix + ";;"},
+		{"case x y in a) ;; esac", errorTextPrefix + "y"},
+		{"case x; esac", errorTextPrefix + ";"},
+		{"case x in a b) ;; esac", errorTextPrefix + "b"},
+		{"case x in |a) ;; esac", errorTextPrefix + "|"},
+		{"case x in a|) ;; esac", errorTextPrefix + ")"},
+		{"case x in a > b) ;; esac", errorTextPrefix + ">"},
+		{"case x in a) echo &&;; esac", errorTextPrefix + ";;"},
+		{"case x in a) if true; then ;; fi; esac", errorTextPrefix + ";;"},
+		{"case x in a) done; esac", errorTextPrefix + "done"},
+		{"for x in a; esac", errorTextPrefix + "esac"},
+		{"case x in a) ;; esac ls", errorTextPrefix + "ls"},
+		{"{ }", errorTextPrefix + "}"},
+		{"}", errorTextPrefix + "}"},
+		{"if true; then :; }", errorTextPrefix + "}"},
+		{"function ;", errorTextPrefix + ";"},
+		{"function f echo", errorTextPrefix + "echo"},
+		{"function 12 { :; }", errorTextPrefix + "12"},
+		{"f() echo hi", errorTextPrefix + "echo"},
+		{"f() { :; } ls", errorTextPrefix + "ls"},
+	}
+
+	for _, test := range tests {
+		result := scanTokensHelper(test.cmd)
+
+		if result != nil {
+			t.Errorf("Scan('%s') got %v. Expected nil", test.cmd, result)
+		}
+		if EieneErrors.HadIncompleteInput {
+			t.Errorf("Scan('%s') was not expected to be incomplete", test.cmd)
+		}
+
+		errorText := EieneErrors.Error()
+		if errorText != test.expectedErrorText {
+			t.Errorf("Scan('%s') got error message %s. Expected %s.", test.cmd, errorText, test.expectedErrorText)
+		}
+	}
+}
@@ -2,9 +2,19 @@ package token
 
 type TokenType string
 
+// Location of a token in the source.
+// Line and Column start at 1, Column counts characters and Offset counts bytes.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
 type Token struct {
 	Type   TokenType
-	Lexeme string
+	Lexeme string // Words have their quotes removed
+	Raw    string // Source text of a word as written, with its quotes
+	Pos    Position
 }
 
 const (
@@ -14,10 +24,71 @@ const (
 	PROG_NAME TokenType = "PROGRAM_NAME"
 	ARG       TokenType = "ARGUMENT"
 
+	// Pattern of a clause of a case command eg *.go in *.go)
+	PATTERN TokenType = "PATTERN"
+
+	// NAME=value before the program name, sets a variable
+	ASSIGNMENT_WORD TokenType = "ASSIGNMENT_WORD"
+
+	// ((expr)) command, the lexeme is the arithmetic expression
+	ARITHMETIC_CMD TokenType = "ARITHMETIC_COMMAND"
+
+	// Reserved words, recognised where a command starts
+	IF   TokenType = "IF"
+	THEN TokenType = "THEN"
+	ELIF TokenType = "ELIF"
+	ELSE TokenType = "ELSE"
+	FI   TokenType = "FI"
+
+	WHILE TokenType = "WHILE"
+	UNTIL TokenType = "UNTIL"
+	DO    TokenType = "DO"
+	DONE  TokenType = "DONE"
+
+	FOR TokenType = "FOR"
+	IN  TokenType = "IN" // Only after the name of a for loop or the word of a case
+
+	CASE TokenType = "CASE"
+	ESAC TokenType = "ESAC"
+
+	LBRACE TokenType = "LBRACE" // { grouping commands
+	RBRACE TokenType = "RBRACE" // }
+
+	FUNCTION TokenType = "FUNCTION"
+
+	// Name of a function being defined eg f in f() or function f
+	FUNCTION_NAME TokenType = "FUNCTION_NAME"
+
 	// Separate commands
 	SEMICOLON TokenType = "SEMICOLON"
+	NEWLINE   TokenType = "NEWLINE"
+	AMPERSAND TokenType = "AMPERSAND" // Runs the command before it in the background
+
+	// End a clause of a case command
+	DSEMI    TokenType = "DSEMI"    // ;;
+	SEMIAND  TokenType = "SEMIAND"  // ;& runs the next clause
+	DSEMIAND TokenType = "DSEMIAND" // ;;& tests the patterns of the next clauses
+
+	// Pipe the output of a command to the next one
+	PIPE TokenType = "PIPE" // |
+
+	// Redirections. The lexeme starts with the redirected file descriptor if given eg 2>
+	LESS      TokenType = "LESS"      // <
+	GREAT     TokenType = "GREAT"     // >
+	DGREAT    TokenType = "DGREAT"    // >>
+	LESSGREAT TokenType = "LESSGREAT" // <>
+	LESSAND   TokenType = "LESSAND"   // <&
+	GREATAND  TokenType = "GREATAND"  // >&
+	ANDGREAT  TokenType = "ANDGREAT"  // &>
+	ANDDGREAT TokenType = "ANDDGREAT" // &>>
 
 	// Logical
 	AND TokenType = "AND" // &&
 	OR  TokenType = "OR"  // ||
 )
+
+// Tokens of the operators redirecting input and output
+var REDIRECTIONS = []TokenType{
+	LESS, GREAT, DGREAT, LESSGREAT,
+	LESSAND, GREATAND, ANDGREAT, ANDDGREAT,
+}